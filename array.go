@@ -0,0 +1,159 @@
+package jsonpb
+
+import (
+	"io"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// transJsonTopArray 把顶层 JSON 数组的每个元素按 msg 编码。
+// tag 非 0 时每个元素作为 tag 对应的 repeated 消息字段写出，否则以 varint 长度前缀写出。
+func (st *jtopState) transJsonTopArray(p *proto.Encoder, j JsonLexer, msg *Message, tag uint32) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
+	// 每个元素直接编码到 p 中，与嵌套的子消息一样预留长度前缀，tag 为 0 时只有长度前缀
+	begin := func() {
+		if tag != 0 {
+			p.BeginMessage(tag)
+		} else {
+			p.BeginDelimited()
+		}
+	}
+	n := 0
+	start := p.Len()
+	for !j.EOF() {
+		tok, _ := j.Next()
+		switch tok {
+		case jsonlit.ArrayClose:
+			return nil
+		case jsonlit.Comma:
+		case jsonlit.Object, jsonlit.Null:
+			n++
+			if err := st.limits.checkRepeated(n); err != nil {
+				return err
+			}
+			if err := checkFull(p, start); err != nil {
+				return err
+			}
+			// null 与 transJsonRepeatedMessage 一致，表达为一个空消息
+			begin()
+			var err error
+			if tok == jsonlit.Object {
+				err = st.transJsonObject(p, j, msg)
+			}
+			size := p.EndMessage()
+			if err != nil {
+				return err
+			}
+			st.sortOutput(p, p.Len()-size, msg)
+		default:
+			return ErrUnexpectedToken
+		}
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// TranscodeArray 按 o 指定的选项转译顶层为数组的 JSON，见 TranscodeArrayToProto。
+func (o *ToProtoOptions) TranscodeArray(p *proto.Encoder, j JsonLexer, msg *Message, tag uint32) error {
+	st := newJtopState(o)
+	if err := st.begin(j); err != nil {
+		return err
+	}
+	var err error
+	tok, _ := j.Next()
+	switch tok {
+	case jsonlit.Array:
+		err = st.transJsonTopArray(p, j, msg, tag)
+	case jsonlit.EOF:
+		err = io.ErrUnexpectedEOF
+	default:
+		err = ErrUnexpectedToken
+	}
+	return st.end(err)
+}
+
+// TranscodeArrayToProto 解析顶层为对象数组的 JSON（如 `[{...},{...}]`），每个元素按 msg 转译。
+// tag 非 0 时结果是一个只含 tag 号 repeated 消息字段的外层消息；
+// tag 为 0 时结果是 varint 长度前缀的消息流（与 TranscodeNdjsonToDelimited 的输出格式相同）。
+func TranscodeArrayToProto(p *proto.Encoder, j JsonLexer, msg *Message, tag uint32) error {
+	return defaultToProtoOptions.TranscodeArray(p, j, msg, tag)
+}
+
+// transProtoTopArray 是 transJsonTopArray 的逆过程，输出一个 JSON 数组。
+// tag 非 0 时忽略外层消息中的其它字段。
+func (st *ptojState) transProtoTopArray(j *JsonBuilder, p *proto.Decoder, msg *Message, tag uint32) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
+	j.AppendByte('[')
+	n := 0
+	for !p.EOF() {
+		var s proto.Value
+		if tag != 0 {
+			t, wire, e := p.ReadTag()
+			if e < 0 {
+				return p.ErrorOf(e, 0, 0)
+			}
+			if t != tag {
+				if e := p.SkipField(t, wire); e < 0 {
+					return p.ErrorOf(e, t, wire)
+				}
+				continue
+			}
+			val, e := p.ReadValue(t, wire)
+			if e < 0 {
+				return p.ErrorOf(e, t, wire)
+			}
+			if wire != protowire.BytesType {
+				return ErrInvalidWireType
+			}
+			s = val
+		} else {
+			val, e := p.ReadValue(0, protowire.BytesType)
+			if e < 0 {
+				return p.ErrorOf(e, 0, protowire.BytesType)
+			}
+			s = val
+		}
+		n++
+		if err := st.limits.checkRepeated(n); err != nil {
+			return err
+		}
+		if n > 1 {
+			j.AppendByte(',')
+		}
+		if err := j.flushFull(); err != nil {
+			return err
+		}
+		if err := st.transProtoMessage(j, proto.NewDecoderAt(s.S, s.Off), msg); err != nil {
+			return err
+		}
+	}
+	j.AppendByte(']')
+	return nil
+}
+
+// TranscodeArray 按 o 指定的选项把 pb 转译为顶层为数组的 JSON，见 TranscodeArrayToJson。
+func (o *ToJsonOptions) TranscodeArray(j *JsonBuilder, p *proto.Decoder, msg *Message, tag uint32) error {
+	st := newPtojState(o)
+	if err := st.limits.checkInputSize(p.Len()); err != nil {
+		return err
+	}
+	if err := st.transProtoTopArray(j, p, msg, tag); err != nil {
+		return err
+	}
+	return j.Flush()
+}
+
+// TranscodeArrayToJson 是 TranscodeArrayToProto 的逆过程：把 tag 号 repeated 消息字段的所有元素
+// （tag 为 0 时为 varint 长度前缀消息流中的所有消息）按 msg 转译为 JSON 数组追加到 j 中。
+func TranscodeArrayToJson(j *JsonBuilder, p *proto.Decoder, msg *Message, tag uint32) error {
+	return defaultToJsonOptions.TranscodeArray(j, p, msg, tag)
+}
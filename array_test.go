@@ -0,0 +1,71 @@
+package jsonpb
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func TestTranscodeArrayToProto(t *testing.T) {
+	tests := []struct {
+		name    string
+		j       string
+		tag     uint32
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", j: `[]`, tag: 1, want: ""},
+		{name: "repeated_tag", j: `[{"name":"bob"},null,{"age":23}]`, tag: 1, want: "0a050a03626f620a000a021017"},
+		{name: "delimited", j: `[{"name":"bob"},null,{"age":23}]`, tag: 0, want: "050a03626f6200021017"},
+		// 元素超过 127 字节时长度前缀不止 1 字节；key 乱序时元素内的字段按 tag 重排
+		{name: "long_delimited", j: `[{"age":23,"name":"` + strings.Repeat("x", 130) + `"}]`, tag: 0, want: "8701" + "0a8201" + strings.Repeat("78", 130) + "1017"},
+		{name: "long_repeated_tag", j: `[{"age":23,"name":"` + strings.Repeat("x", 130) + `"}]`, tag: 1, want: "0a8701" + "0a8201" + strings.Repeat("78", 130) + "1017"},
+		{name: "object_root", j: `{}`, tag: 1, wantErr: true},
+		{name: "bad_element", j: `[1]`, tag: 1, wantErr: true},
+		{name: "unterminated", j: `[{}`, tag: 1, wantErr: true},
+		{name: "eof", j: ``, tag: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p proto.Encoder
+			err := TranscodeArrayToProto(&p, jsonlit.NewIter([]byte(tt.j)), getTestSimpleMessage(), tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TranscodeArrayToProto() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := hex.EncodeToString(p.Bytes()); !tt.wantErr && got != tt.want {
+				t.Errorf("TranscodeArrayToProto() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscodeArrayToJson(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       string
+		tag     uint32
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", p: "", tag: 1, want: `[]`},
+		{name: "repeated_tag", p: "0a050a03626f62" + "1805" + "0a00" + "0a021017", tag: 1, want: `[{"name":"bob"},{},{"age":23}]`},
+		{name: "delimited", p: "050a03626f6200021017", tag: 0, want: `[{"name":"bob"},{},{"age":23}]`},
+		{name: "bad_wire", p: "0805", tag: 1, wantErr: true},
+		{name: "truncated", p: "05", tag: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var j JsonBuilder
+			err := TranscodeArrayToJson(&j, proto.NewDecoder(decodeBytes(tt.p)), getTestSimpleMessage(), tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TranscodeArrayToJson() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && j.String() != tt.want {
+				t.Errorf("TranscodeArrayToJson() = %v, want %v", j.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package jsonpb
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+const benchComplexJson = `{"fdouble":123.5,"ffloat":1.25,"fint32":-123,"fint64":123456789,"fuint32":123,"fuint64":123,"fsint32":-7,"fsint64":7,"ffixed32":9,"ffixed64":9,"fsfixed32":-9,"fsfixed64":9,"fbool":true,"fstring":"hello \"world\" 你好","fbytes":"aGVsbG8gd29ybGQ=","fmap1":{"a":1,"b":2},"fmap2":{"u":{"name":"abc","age":23},"v":{"name":"def"}},"fsubmsg":{"name":"efg","age":24},"fint32s":[1,2,3,4,5],"fitems":[{"name":"abc","age":12},{"name":"d\/e"},{}]}`
+
+func BenchmarkTranscodeToProto(b *testing.B) {
+	msg := getTestComplexMessage()
+	data := []byte(benchComplexJson)
+	var p proto.Encoder
+	it := jsonlit.NewIter(data)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Clear()
+		it.Reset(data)
+		if err := TranscodeToProto(&p, it, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSizeOfJson(b *testing.B) {
+	msg := getTestComplexMessage()
+	data := []byte(benchComplexJson)
+	it := jsonlit.NewIter(data)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		it.Reset(data)
+		if _, err := SizeOfJson(it, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranscodeToProto_reader(b *testing.B) {
+	msg := getTestComplexMessage()
+	data := []byte(benchComplexJson)
+	var (
+		p proto.Encoder
+		r bytes.Reader
+	)
+	it := jsonlit.NewReaderIter(nil, 0)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Clear()
+		r.Reset(data)
+		it.Reset(&r)
+		if err := TranscodeToProto(&p, it, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranscodeToProto_deterministic(b *testing.B) {
+	msg := getTestComplexMessage()
+	data := []byte(benchComplexJson)
+	opts := ToProtoOptions{Deterministic: true}
+	var p proto.Encoder
+	it := jsonlit.NewIter(data)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Clear()
+		it.Reset(data)
+		if err := opts.Transcode(&p, it, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTranscodeToProto_allocs(t *testing.T) {
+	msg := getTestComplexMessage()
+	data := []byte(benchComplexJson)
+	var p proto.Encoder
+	it := jsonlit.NewIter(data)
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Clear()
+		it.Reset(data)
+		if err := TranscodeToProto(&p, it, msg); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// 允许 GC 清空 sync.Pool 导致的偶发分配
+	if allocs >= 1 {
+		t.Errorf("TranscodeToProto allocs = %v, want 0", allocs)
+	}
+}
+
+func benchComplexProto(b *testing.B, deterministic bool) []byte {
+	var p proto.Encoder
+	opts := ToProtoOptions{Deterministic: deterministic}
+	if err := opts.Transcode(&p, jsonlit.NewIter([]byte(benchComplexJson)), getTestComplexMessage()); err != nil {
+		b.Fatal(err)
+	}
+	return p.Bytes()
+}
+
+func benchmarkTranscodeToJson(b *testing.B, data []byte) {
+	msg := getTestComplexMessage()
+	var j JsonBuilder
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		j.buf = j.buf[:0]
+		if err := TranscodeToJson(&j, proto.NewDecoder(data), msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranscodeToJson(b *testing.B) {
+	benchmarkTranscodeToJson(b, benchComplexProto(b, true))
+}
+
+// 字段乱序时回退到两遍算法
+func BenchmarkTranscodeToJson_unordered(b *testing.B) {
+	data := benchComplexProto(b, true)
+	// 把第一个字段（fdouble，9 字节）移到末尾
+	data = append(append([]byte(nil), data[9:]...), data[:9]...)
+	benchmarkTranscodeToJson(b, data)
+}
+
+// 深层嵌套的消息流式输出：字段顺序只在最外层检查一次，开销与嵌套深度成线性
+func BenchmarkTranscodeToJson_deepStream(b *testing.B) {
+	node := &Message{Name: "Node"}
+	node.Fields = []Field{
+		{Name: "name", Kind: StringKind, Tag: 1},
+		{Name: "child", Kind: MessageKind, Tag: 2, Ref: node, Omit: OmitEmpty},
+	}
+	node.BakeTagIndex()
+	node.BakeNameIndex()
+	const depth = 5000
+	var p proto.Encoder
+	for i := 0; i < depth; i++ {
+		p.EmitString(1, "node")
+		p.BeginMessage(2)
+	}
+	for i := 0; i < depth; i++ {
+		p.EndMessage()
+	}
+	data := p.Bytes()
+	j := NewStreamJsonBuilder(io.Discard, 0)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := TranscodeToJson(j, proto.NewDecoder(data), node); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 网关场景：每次请求从池中取得缓冲并按估计的大小预留
+func BenchmarkTranscodeToJson_pooled(b *testing.B) {
+	msg := getTestComplexMessage()
+	data := benchComplexProto(b, true)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		j := AcquireJsonBuilder(EstimateJsonSize(msg, len(data)))
+		if err := TranscodeToJson(j, proto.NewDecoder(data), msg); err != nil {
+			b.Fatal(err)
+		}
+		j.Release()
+	}
+}
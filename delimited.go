@@ -0,0 +1,91 @@
+package jsonpb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// TranscodeDelimited 按 o 指定的选项把 varint 长度前缀的 pb 记录流转译为 NDJSON，见 TranscodeDelimitedToNdjson。
+func (o *ToJsonOptions) TranscodeDelimited(j *JsonBuilder, r *proto.ReaderDecoder, msg *Message) error {
+	st := newPtojState(o)
+	// 在读取记录内容之前按长度前缀检查 MaxInputSize
+	r.SetMaxLen(st.limits.MaxInputSize)
+	for i := 0; !r.EOF(); i++ {
+		rec, err := r.ReadBytes()
+		if err != nil {
+			if errors.Is(err, proto.ErrTooLarge) {
+				err = &LimitError{Limit: "input size", Max: st.limits.MaxInputSize}
+			}
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if err := st.transProtoMessage(j, proto.NewDecoderAt(rec, r.Offset()-int64(len(rec))), msg); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		j.AppendByte('\n')
+		if err := j.flushFull(); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return j.Flush()
+}
+
+// TranscodeDelimitedToNdjson 读取 varint 长度前缀的 pb 记录流（即 writeDelimitedTo 的格式），
+// 把每条记录按 msg 转译为一行 JSON 追加到 j 中。Limits 按单条记录计算。
+func TranscodeDelimitedToNdjson(j *JsonBuilder, r *proto.ReaderDecoder, msg *Message) error {
+	return defaultToJsonOptions.TranscodeDelimited(j, r, msg)
+}
+
+// TranscodeNdjson 按 o 指定的选项把 NDJSON 转译为 varint 长度前缀的 pb 记录流，见 TranscodeNdjsonToDelimited。
+func (o *ToProtoOptions) TranscodeNdjson(w io.Writer, j JsonLexer, msg *Message) error {
+	var (
+		rec proto.Encoder
+		hdr []byte
+	)
+	st := newJtopState(o)
+	for i := 0; ; i++ {
+		// 每条记录单独 begin，MaxInputSize 与 ReaderIter 的窗口上限都按单条记录生效
+		err := st.beginRecord(j)
+		if err == nil {
+			tok, _ := j.Next()
+			switch tok {
+			case jsonlit.EOF:
+				return st.end(nil)
+			case jsonlit.Object:
+				rec.Clear()
+				if err = st.transJsonObject(&rec, j, msg); err == nil {
+					st.sortOutput(&rec, 0, msg)
+				}
+			default:
+				err = ErrUnexpectedToken
+			}
+		}
+		if err = st.end(err); err != nil {
+			// 读取错误原样返回
+			if st.reader != nil && st.reader.Err() != nil && st.reader.Err() != jsonlit.ErrTokenTooLarge {
+				return err
+			}
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		hdr = protowire.AppendVarint(hdr[:0], uint64(rec.Len()))
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(rec.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// TranscodeNdjsonToDelimited 读取 NDJSON（以空白分隔的若干 JSON 对象），
+// 把每个对象按 msg 转译为 pb 并以 varint 长度前缀写入 w。Limits 按单条记录计算。
+func TranscodeNdjsonToDelimited(w io.Writer, j JsonLexer, msg *Message) error {
+	return defaultToProtoOptions.TranscodeNdjson(w, j, msg)
+}
@@ -0,0 +1,110 @@
+package jsonpb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func TestTranscodeDelimitedToNdjson(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       string
+		want    string
+		wantErr error
+	}{
+		{name: "empty", p: "", want: ""},
+		{name: "records", p: "09" + "0a03626f6210171801" + "00" + "02" + "1017", want: "{\"name\":\"bob\",\"age\":23}\n{}\n{\"age\":23}\n"},
+		{name: "truncated", p: "09" + "0a03626f62", wantErr: io.ErrUnexpectedEOF},
+		{name: "bad_record", p: "02" + "0a05", wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var j JsonBuilder
+			r := proto.NewReaderDecoder(bytes.NewReader(decodeBytes(tt.p)), 0)
+			err := TranscodeDelimitedToNdjson(&j, r, getTestSimpleMessage())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TranscodeDelimitedToNdjson() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && j.String() != tt.want {
+				t.Errorf("TranscodeDelimitedToNdjson() = %q, want %q", j.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscodeNdjsonToDelimited(t *testing.T) {
+	const ndjson = "{\"name\":\"bob\",\"age\":23}\n{}\n\n{\"age\":23}\n"
+	// male 为 OmitAlways，不写入
+	const want = "07" + "0a03626f621017" + "00" + "02" + "1017"
+	for _, j := range []JsonLexer{
+		jsonlit.NewIter([]byte(ndjson)),
+		jsonlit.NewReaderIter(strings.NewReader(ndjson), 3),
+	} {
+		var w bytes.Buffer
+		if err := TranscodeNdjsonToDelimited(&w, j, getTestSimpleMessage()); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Bytes(); !bytes.Equal(got, decodeBytes(want)) {
+			t.Fatalf("got %x, want %s", got, want)
+		}
+
+		// 往返
+		var out JsonBuilder
+		if err := TranscodeDelimitedToNdjson(&out, proto.NewReaderDecoder(&w, 0), getTestSimpleMessage()); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != "{\"name\":\"bob\",\"age\":23}\n{}\n{\"age\":23}\n" {
+			t.Fatal(out.String())
+		}
+	}
+
+	err := TranscodeNdjsonToDelimited(io.Discard, jsonlit.NewIter([]byte(`{} [] {}`)), getTestSimpleMessage())
+	if !errors.Is(err, ErrUnexpectedToken) {
+		t.Fatalf("TranscodeNdjsonToDelimited() error = %v", err)
+	}
+	opts := ToProtoOptions{Limits: &Limits{MaxInputSize: 10}}
+	err = opts.TranscodeNdjson(io.Discard, jsonlit.NewIter([]byte(`{"age":1} {"name":"abcdef"}`)), getTestSimpleMessage())
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("TranscodeNdjson() error = %v", err)
+	}
+}
+
+func TestDelimitedRecordLimits(t *testing.T) {
+	// 长度前缀声明 8MiB 的记录，应在读取内容之前报告超限
+	p := append(decodeBytes("8080808004"), make([]byte, 64<<10)...)
+	r := &countReader{r: bytes.NewReader(p)}
+	jopts := ToJsonOptions{Limits: &Limits{MaxInputSize: 100}}
+	var j JsonBuilder
+	err := jopts.TranscodeDelimited(&j, proto.NewReaderDecoder(r, 0), getTestSimpleMessage())
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "input size" {
+		t.Fatalf("TranscodeDelimited() error = %v", err)
+	}
+	if r.n > 16<<10 {
+		t.Fatalf("read %d bytes before the limit error", r.n)
+	}
+
+	// 超长的字符串受 ReaderIter 窗口上限限制，不会整体读入内存
+	ndjson := `{"age":1}` + "\n" + `{"name":"` + strings.Repeat("a", 8<<20) + `"}`
+	r = &countReader{r: strings.NewReader(ndjson)}
+	popts := ToProtoOptions{Limits: &Limits{MaxInputSize: 100}}
+	err = popts.TranscodeNdjson(io.Discard, jsonlit.NewReaderIter(r, 0), getTestSimpleMessage())
+	if !errors.As(err, &le) || le.Limit != "input size" {
+		t.Fatalf("TranscodeNdjson() error = %v", err)
+	}
+	if r.n > 16<<10 {
+		t.Fatalf("read %d bytes before the limit error", r.n)
+	}
+
+	// 每条记录单独计算，总长超过 MaxInputSize 不影响
+	err = popts.TranscodeNdjson(io.Discard, jsonlit.NewIter([]byte(strings.Repeat(`{"age":1}`+"\n", 50))), getTestSimpleMessage())
+	if err != nil {
+		t.Fatalf("TranscodeNdjson() error = %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package jsonpb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	ErrUnsupportedKind = errors.New("unsupported field kind")
+)
+
+var kindOfProtoKind = map[protoreflect.Kind]Kind{
+	protoreflect.DoubleKind:   DoubleKind,
+	protoreflect.FloatKind:    FloatKind,
+	protoreflect.Int32Kind:    Int32Kind,
+	protoreflect.Int64Kind:    Int64Kind,
+	protoreflect.Uint32Kind:   Uint32Kind,
+	protoreflect.Uint64Kind:   Uint64Kind,
+	protoreflect.Sint32Kind:   Sint32Kind,
+	protoreflect.Sint64Kind:   Sint64Kind,
+	protoreflect.Fixed32Kind:  Fixed32Kind,
+	protoreflect.Fixed64Kind:  Fixed64Kind,
+	protoreflect.Sfixed32Kind: Sfixed32Kind,
+	protoreflect.Sfixed64Kind: Sfixed64Kind,
+	protoreflect.BoolKind:     BoolKind,
+	protoreflect.StringKind:   StringKind,
+	protoreflect.BytesKind:    BytesKind,
+	protoreflect.MessageKind:  MessageKind,
+	protoreflect.GroupKind:    GroupKind,
+	// enum 在 wire 上为 int32，JSON 侧使用数值
+	protoreflect.EnumKind: Int32Kind,
+}
+
+// NewMessageFromDescriptor 根据 protobuf 描述符生成元数据，同一描述符的嵌套/递归引用共享同一个 *Message。
+// 字段名使用描述符的 JSON 名（与 protojson 一致），非 packed 的 repeated 标量字段设置 Unpacked，
+// proto2 的 required 与 [default = ...] 分别设置 Required 与 Default，proto3 的 string 字段设置 ValidateUTF8。
+func NewMessageFromDescriptor(md protoreflect.MessageDescriptor) (*Message, error) {
+	return newMessageFromDescriptor(md, make(map[protoreflect.FullName]*Message))
+}
+
+func newMessageFromDescriptor(md protoreflect.MessageDescriptor, cache map[protoreflect.FullName]*Message) (*Message, error) {
+	if msg := cache[md.FullName()]; msg != nil {
+		return msg, nil
+	}
+	msg := &Message{Name: string(md.FullName())}
+	cache[md.FullName()] = msg
+
+	fds := md.Fields()
+	fields := make([]Field, fds.Len())
+	for i := range fields {
+		fd := fds.Get(i)
+		field := &fields[i]
+		field.Name = fd.JSONName()
+		field.Tag = uint32(fd.Number())
+		if fd.IsMap() {
+			entry, err := newMapEntryFromDescriptor(fd, cache)
+			if err != nil {
+				return nil, err
+			}
+			field.Kind = MapKind
+			field.Ref = entry
+			continue
+		}
+		kind, ok := kindOfProtoKind[fd.Kind()]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", fd.FullName(), ErrUnsupportedKind)
+		}
+		field.Kind = kind
+		if kind == MessageKind || kind == GroupKind {
+			ref, err := newMessageFromDescriptor(fd.Message(), cache)
+			if err != nil {
+				return nil, err
+			}
+			field.Ref = ref
+		}
+		if fd.IsList() {
+			field.Repeated = true
+			field.Unpacked = (IsNumericKind(kind) || kind == BoolKind) && !fd.IsPacked()
+		}
+		field.Required = fd.Cardinality() == protoreflect.Required
+		field.ValidateUTF8 = validatesUTF8(fd)
+		if fd.HasDefault() {
+			field.Default = jsonDefaultValue(fd, kind)
+		}
+	}
+	msg.Fields = fields
+	msg.BakeTagIndex()
+	msg.BakeNameIndex()
+	return msg, nil
+}
+
+func newMapEntryFromDescriptor(fd protoreflect.FieldDescriptor, cache map[protoreflect.FullName]*Message) (*Message, error) {
+	kd, vd := fd.MapKey(), fd.MapValue()
+	key, ok := kindOfProtoKind[kd.Kind()]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", kd.FullName(), ErrUnsupportedKind)
+	}
+	value, ok := kindOfProtoKind[vd.Kind()]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", vd.FullName(), ErrUnsupportedKind)
+	}
+	var ref *Message
+	if value == MessageKind {
+		var err error
+		ref, err = newMessageFromDescriptor(vd.Message(), cache)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewMessage("", []Field{
+		0: {Tag: 1, Kind: key, ValidateUTF8: validatesUTF8(kd)},
+		1: {Tag: 2, Kind: value, Ref: ref, ValidateUTF8: validatesUTF8(vd)},
+	}, true, true), nil
+}
+
+// validatesUTF8 判断 fd 是否要求合法 UTF-8：与 protobuf 一致，只有 proto3 的 string 字段检查。
+func validatesUTF8(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.StringKind && fd.Syntax() == protoreflect.Proto3
+}
+
+// jsonDefaultValue 把字段声明的默认值格式化为 proto->json 输出的 JSON 字面量。
+func jsonDefaultValue(fd protoreflect.FieldDescriptor, kind Kind) string {
+	var j JsonBuilder
+	v := fd.Default()
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		transProtoString(&j, []byte(v.String()), UTF8Replace)
+	case protoreflect.BytesKind:
+		transProtoBytes(&j, v.Bytes(), false)
+	case protoreflect.EnumKind:
+		transProtoSimpleValue(&j, kind, uint64(v.Enum()))
+	case protoreflect.BoolKind:
+		transProtoSimpleValue(&j, kind, protowire.EncodeBool(v.Bool()))
+	case protoreflect.DoubleKind:
+		transProtoSimpleValue(&j, kind, math.Float64bits(v.Float()))
+	case protoreflect.FloatKind:
+		transProtoSimpleValue(&j, kind, uint64(math.Float32bits(float32(v.Float()))))
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		transProtoSimpleValue(&j, kind, protowire.EncodeZigZag(v.Int()))
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+		transProtoSimpleValue(&j, kind, uint64(v.Int()))
+	default:
+		transProtoSimpleValue(&j, kind, v.Uint())
+	}
+	return j.String()
+}
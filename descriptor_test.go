@@ -0,0 +1,181 @@
+package jsonpb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestNewMessageFromDescriptor(t *testing.T) {
+	_, md := getTestDetMessage()
+	msg, err := NewMessageFromDescriptor(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Name != "test.Det" || len(msg.Fields) != 7 {
+		t.Fatalf("msg = %s %d", msg.Name, len(msg.Fields))
+	}
+	child := msg.FieldByName("child")
+	if child == nil || child.Tag != 4 || child.Kind != MessageKind || child.Ref != msg {
+		t.Fatalf("child = %+v", child)
+	}
+	ids := msg.FieldByTag(5)
+	if ids == nil || ids.Kind != MapKind || ids.Ref.FieldByTag(1).Kind != Sint64Kind || ids.Ref.FieldByTag(2).Kind != StringKind {
+		t.Fatalf("ids = %+v", ids)
+	}
+	subs := msg.FieldByName("subs")
+	if subs.Ref.FieldByTag(2).Ref.Name != "test.Det" {
+		t.Fatalf("subs value = %+v", subs.Ref.FieldByTag(2).Ref)
+	}
+	if nums := msg.FieldByName("nums"); !nums.Repeated || nums.Unpacked {
+		t.Fatalf("nums = %+v", nums)
+	}
+	if !msg.FieldByName("name").ValidateUTF8 || !ids.Ref.FieldByTag(2).ValidateUTF8 || ids.Ref.FieldByTag(1).ValidateUTF8 {
+		t.Fatal("proto3 string fields should set ValidateUTF8")
+	}
+
+	p2, err := NewMessageFromDescriptor(getTestProto2Descriptor("P2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked := map[string]bool{"ints": true, "doubles": true, "packed": false, "flags": true, "colors": true, "names": false}
+	for name, want := range unpacked {
+		if f := p2.FieldByName(name); f.Unpacked != want {
+			t.Errorf("%s.Unpacked = %v, want %v", name, f.Unpacked, want)
+		}
+	}
+	if f := p2.FieldByName("colors"); f.Kind != Int32Kind {
+		t.Errorf("colors.Kind = %v", f.Kind)
+	}
+	if p2.FieldByName("names").ValidateUTF8 {
+		t.Error("proto2 string fields should not set ValidateUTF8")
+	}
+}
+
+func TestTranscodeToProto_unpacked(t *testing.T) {
+	md := getTestProto2Descriptor("P2")
+	msg, err := NewMessageFromDescriptor(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const j = `{"ints":[1,-2,0],"doubles":[1.5,0],"packed":[-1,2],"flags":[true,false],"colors":[1,0],"names":["a",""]}`
+	m := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal([]byte(j), m); err != nil {
+		t.Fatal(err)
+	}
+	want, err := gproto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dynamicpb 的字段顺序不固定，使用 Deterministic 对比
+	var got proto.Encoder
+	opts := ToProtoOptions{Deterministic: true}
+	if err := opts.Transcode(&got, jsonlit.NewIter([]byte(j)), msg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("Transcode() = %x, want %x", got.Bytes(), want)
+	}
+
+	// proto->json 方向仍然接受 unpacked 编码
+	var b JsonBuilder
+	if err := TranscodeToJson(&b, proto.NewDecoder(got.Bytes()), msg); err != nil {
+		t.Fatal(err)
+	}
+	back := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal([]byte(b.String()), back); err != nil {
+		t.Fatal(err)
+	}
+	if !gproto.Equal(back, m) {
+		t.Fatalf("TranscodeToJson() = %s", b.String())
+	}
+}
+
+func TestNewMessageFromDescriptor_proto2(t *testing.T) {
+	msg, err := NewMessageFromDescriptor(getTestProto2Descriptor("Req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.FieldByName("id").Required || msg.FieldByName("name").Required {
+		t.Fatal("Required not set")
+	}
+	defaults := map[string]string{
+		"id":    "",
+		"name":  `"n\"x"`,
+		"ratio": "Infinity",
+		"color": "1",
+		"raw":   `"AQI="`,
+		"delta": "-3",
+		"child": "",
+		"on":    "true",
+	}
+	for name, want := range defaults {
+		if got := msg.FieldByName(name).Default; got != want {
+			t.Errorf("%s.Default = %s, want %s", name, got, want)
+		}
+	}
+
+	var b JsonBuilder
+	if err := TranscodeToJson(&b, proto.NewDecoder([]byte{0x08, 0x07}), msg); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"id":7,"name":"n\"x","ratio":Infinity,"color":1,"raw":"AQI=","delta":-3,"child":{},"on":true}`
+	if b.String() != want {
+		t.Fatalf("TranscodeToJson() = %s, want %s", b.String(), want)
+	}
+}
+
+func TestTranscode_group(t *testing.T) {
+	md := getTestProto2Descriptor("Legacy")
+	msg, err := NewMessageFromDescriptor(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := msg.FieldByName("entry"); f.Kind != GroupKind || !f.Repeated || f.Ref.FieldByName("k") == nil {
+		t.Fatalf("entry = %+v", f)
+	}
+	const j = `{"id":1,"item":{"name":"x","vals":[1,2]},"entry":[{"k":1},{}]}`
+	m := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal([]byte(j), m); err != nil {
+		t.Fatal(err)
+	}
+	want, err := gproto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got proto.Encoder
+	opts := ToProtoOptions{Deterministic: true}
+	if err := opts.Transcode(&got, jsonlit.NewIter([]byte(j)), msg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("Transcode() = %x, want %x", got.Bytes(), want)
+	}
+
+	const wantJson = `{"id":1,"item":{"name":"x","vals":[1,2]},"entry":[{"k":1},{"k":0}]}`
+	var b JsonBuilder
+	if err := TranscodeToJson(&b, proto.NewDecoder(want), msg); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != wantJson {
+		t.Fatalf("TranscodeToJson() = %s, want %s", b.String(), wantJson)
+	}
+	b = JsonBuilder{}
+	if err := TranscodeReaderToJson(&b, proto.NewReaderDecoder(bytes.NewReader(want), 1), msg); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != wantJson {
+		t.Fatalf("TranscodeReaderToJson() = %s, want %s", b.String(), wantJson)
+	}
+
+	// 未知的 group 字段被跳过
+	var other JsonBuilder
+	if err := TranscodeToJson(&other, proto.NewDecoder(want), NewMessage("", nil, true, true)); err != nil || other.String() != "{}" {
+		t.Fatalf("TranscodeToJson() = %s, %v", other.String(), err)
+	}
+}
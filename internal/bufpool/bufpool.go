@@ -0,0 +1,36 @@
+// Package bufpool 提供按容量分级的缓冲池所需的级别计算，供 JsonBuilder 与 proto.Encoder 的池共用。
+package bufpool
+
+import "math/bits"
+
+const (
+	minClassBits = 8  // 最小级别 256B
+	maxClassBits = 20 // 最大级别 1MiB，容量更大的缓冲不放回池中
+
+	// MaxSize 是池中保留的缓冲容量上限
+	MaxSize = 1 << maxClassBits
+	// NumClasses 是级别数，级别 i 的缓冲容量不小于 256<<i
+	NumClasses = maxClassBits - minClassBits + 1
+)
+
+// AcquireClass 返回容量至少为 n 的缓冲所在的级别与该级别新建缓冲时的容量。
+// n 超过 MaxSize 时级别为 -1，此时容量即 n，不经过池。
+func AcquireClass(n int) (class int, size int) {
+	if n > MaxSize {
+		return -1, n
+	}
+	if n <= 1<<minClassBits {
+		return 0, 1 << minClassBits
+	}
+	b := bits.Len(uint(n - 1))
+	return b - minClassBits, 1 << b
+}
+
+// ReleaseClass 返回容量为 c 的缓冲应放回的级别，即满足 256<<i <= c 的最大 i；
+// 容量过小或超过 MaxSize 的缓冲不放回池中，返回 -1。
+func ReleaseClass(c int) int {
+	if c < 1<<minClassBits || c > MaxSize {
+		return -1
+	}
+	return bits.Len(uint(c)) - 1 - minClassBits
+}
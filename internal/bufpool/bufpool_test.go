@@ -0,0 +1,51 @@
+package bufpool
+
+import "testing"
+
+func TestAcquireClass(t *testing.T) {
+	tests := []struct {
+		n     int
+		class int
+		size  int
+	}{
+		{n: 0, class: 0, size: 256},
+		{n: 256, class: 0, size: 256},
+		{n: 257, class: 1, size: 512},
+		{n: 1000, class: 2, size: 1024},
+		{n: MaxSize, class: NumClasses - 1, size: MaxSize},
+		{n: MaxSize + 1, class: -1, size: MaxSize + 1},
+	}
+	for _, tt := range tests {
+		class, size := AcquireClass(tt.n)
+		if class != tt.class || size != tt.size {
+			t.Errorf("AcquireClass(%d) = %d, %d, want %d, %d", tt.n, class, size, tt.class, tt.size)
+		}
+	}
+}
+
+func TestReleaseClass(t *testing.T) {
+	tests := []struct {
+		c     int
+		class int
+	}{
+		{c: 0, class: -1},
+		{c: 255, class: -1},
+		{c: 256, class: 0},
+		{c: 511, class: 0},
+		{c: 512, class: 1},
+		{c: MaxSize, class: NumClasses - 1},
+		{c: MaxSize + 1, class: -1},
+	}
+	for _, tt := range tests {
+		if class := ReleaseClass(tt.c); class != tt.class {
+			t.Errorf("ReleaseClass(%d) = %d, want %d", tt.c, class, tt.class)
+		}
+	}
+	// 放回的缓冲满足再次取出的容量要求
+	for n := 1; n <= MaxSize; n = n*3/2 + 1 {
+		class, size := AcquireClass(n)
+		if ReleaseClass(size) != class {
+			t.Errorf("ReleaseClass(%d) != AcquireClass(%d)", size, n)
+		}
+	}
+}
@@ -1,15 +1,41 @@
 package jsonpb
 
-import "github.com/vizee/jsonpb/jsonlit"
+import (
+	"io"
+
+	"github.com/vizee/jsonpb/jsonlit"
+)
+
+const defaultStreamBufferSize = 4096
 
 type JsonBuilder struct {
 	buf []byte
+
+	// 以下字段仅在写出到 io.Writer 时使用
+	w    io.Writer
+	size int
+	err  error
 }
 
 func UnsafeJsonBuilder(buf []byte) *JsonBuilder {
 	return &JsonBuilder{buf: buf}
 }
 
+// NewStreamJsonBuilder 创建一个写出到 w 的 JsonBuilder。
+// 内部缓冲累积到约 size 字节后写出到 w，size <= 0 时使用 4096。
+// 转码过程中长字符串/bytes 会分块写出，缓冲不会因单个大值无限增长。
+func NewStreamJsonBuilder(w io.Writer, size int) *JsonBuilder {
+	if size <= 0 {
+		size = defaultStreamBufferSize
+	}
+	return &JsonBuilder{
+		buf:  make([]byte, 0, size+size/2),
+		w:    w,
+		size: size,
+	}
+}
+
+// Len 返回缓冲中的字节数，对 NewStreamJsonBuilder 创建的 JsonBuilder 不包括已写出的部分。
 func (b *JsonBuilder) Len() int {
 	return len(b.buf)
 }
@@ -32,6 +58,27 @@ func (b *JsonBuilder) IntoBytes() []byte {
 	return buf
 }
 
+// Flush 把缓冲写出到 io.Writer，返回首个写出错误。
+// 出错后后续内容会被丢弃。未关联 io.Writer 时什么也不做。
+func (b *JsonBuilder) Flush() error {
+	if b.w == nil {
+		return nil
+	}
+	if b.err == nil && len(b.buf) != 0 {
+		_, b.err = b.w.Write(b.buf)
+	}
+	b.buf = b.buf[:0]
+	return b.err
+}
+
+// flushFull 在缓冲达到阈值时写出，返回首个写出错误，转码据此提前结束而不是继续处理剩余输入。
+func (b *JsonBuilder) flushFull() error {
+	if b.w != nil && len(b.buf) >= b.size {
+		return b.Flush()
+	}
+	return b.err
+}
+
 func (b *JsonBuilder) AppendBytes(s ...byte) {
 	b.buf = append(b.buf, s...)
 }
@@ -45,5 +92,17 @@ func (b *JsonBuilder) AppendByte(c byte) {
 }
 
 func (b *JsonBuilder) AppendEscapedString(s string) {
+	if b.w != nil {
+		// 每个字节转义后最多 6 字节，按 size/6 分块使缓冲不超过约 2*size；转义按字节进行，分块不会破坏 UTF-8 序列
+		chunk := max(b.size/6, 1)
+		for len(s) > chunk {
+			b.buf = jsonlit.EscapeString(b.buf, s[:chunk])
+			s = s[chunk:]
+			if b.flushFull() != nil {
+				// 出错后的内容都会被丢弃，不必再转义
+				return
+			}
+		}
+	}
 	b.buf = jsonlit.EscapeString(b.buf, s)
 }
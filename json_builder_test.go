@@ -1,7 +1,12 @@
 package jsonpb
 
 import (
+	"bytes"
+	"io"
+	"strings"
 	"testing"
+
+	"github.com/vizee/jsonpb/proto"
 )
 
 func TestJsonBuilder(t *testing.T) {
@@ -23,3 +28,106 @@ func TestJsonBuilder(t *testing.T) {
 		t.Fatal("b1.String():", b1.String())
 	}
 }
+
+type chunkWriter struct {
+	out      []byte
+	maxChunk int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.out = append(w.out, p...)
+	w.maxChunk = max(w.maxChunk, len(p))
+	return len(p), nil
+}
+
+func TestStreamJsonBuilder(t *testing.T) {
+	var w chunkWriter
+	b := NewStreamJsonBuilder(&w, 16)
+	b.AppendByte('"')
+	b.AppendEscapedString(strings.Repeat("a\tb", 100))
+	b.AppendByte('"')
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"` + strings.Repeat(`a\tb`, 100) + `"`; string(w.out) != want {
+		t.Fatal("w.out:", string(w.out))
+	}
+	if w.maxChunk > 64 {
+		t.Fatal("maxChunk:", w.maxChunk)
+	}
+}
+
+func TestStreamJsonBuilder_escapeBound(t *testing.T) {
+	// 每个控制字符转义为 6 字节，缓冲仍不超过约 2*size
+	var w chunkWriter
+	b := NewStreamJsonBuilder(&w, 60)
+	b.AppendEscapedString(strings.Repeat("\x01", 1000))
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat(`\u0001`, 1000); string(w.out) != want {
+		t.Fatal("w.out:", string(w.out))
+	}
+	if w.maxChunk > 120 {
+		t.Fatal("maxChunk:", w.maxChunk)
+	}
+}
+
+func TestStreamJsonBuilder_transcode(t *testing.T) {
+	msg := NewMessage("M", []Field{
+		{Name: "s", Tag: 1, Kind: StringKind},
+		{Name: "b", Tag: 2, Kind: BytesKind},
+		{Name: "items", Tag: 3, Kind: MessageKind, Repeated: true, Ref: getTestSimpleMessage()},
+	}, true, true)
+	var enc proto.Encoder
+	enc.EmitString(1, strings.Repeat("x\"", 1000))
+	enc.EmitBytes(2, bytes.Repeat([]byte{1, 2, 3, 4}, 1000))
+	for i := 0; i < 100; i++ {
+		enc.EmitBytes(3, decodeBytes("0a03626f621017"))
+	}
+
+	var want JsonBuilder
+	if err := TranscodeToJson(&want, proto.NewDecoder(enc.Bytes()), msg); err != nil {
+		t.Fatal(err)
+	}
+	var w chunkWriter
+	if err := TranscodeToJson(NewStreamJsonBuilder(&w, 256), proto.NewDecoder(enc.Bytes()), msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(w.out) != want.String() {
+		t.Fatal("w.out:", string(w.out))
+	}
+	if w.maxChunk > 1024 {
+		t.Fatal("maxChunk:", w.maxChunk)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestStreamJsonBuilder_writeError(t *testing.T) {
+	err := TranscodeToJson(NewStreamJsonBuilder(errWriter{}, 0), proto.NewDecoder(nil), getTestSimpleMessage())
+	if err != io.ErrShortWrite {
+		t.Fatal(err)
+	}
+
+	// 写出失败后转码立即结束，不再处理剩余输入
+	msg := NewMessage("M", []Field{
+		{Name: "items", Tag: 1, Kind: MessageKind, Repeated: true, Ref: getTestSimpleMessage()},
+	}, true, true)
+	var enc proto.Encoder
+	for i := 0; i < 1000; i++ {
+		enc.EmitBytes(1, decodeBytes("0a03626f621017"))
+	}
+	dec := proto.NewDecoder(enc.Bytes())
+	err = TranscodeToJson(NewStreamJsonBuilder(errWriter{}, 16), dec, msg)
+	if err != io.ErrShortWrite {
+		t.Fatal(err)
+	}
+	if dec.Offset() > enc.Len()/10 {
+		t.Fatalf("read %d of %d bytes after write error", dec.Offset(), enc.Len())
+	}
+}
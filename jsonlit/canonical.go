@@ -0,0 +1,292 @@
+package jsonlit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidEscape = errors.New("invalid escape sequence")
+	ErrInvalidUTF8   = errors.New("invalid UTF-8 in string")
+	ErrDuplicateKey  = errors.New("duplicate object key")
+	ErrNumberRange   = errors.New("number out of range")
+	ErrLoneSurrogate = errors.New("lone surrogate in \\u escape")
+)
+
+// maxCanonicalDepth 限制 Compact/Canonicalize 的嵌套层数，避免恶意输入耗尽栈
+const maxCanonicalDepth = 10000
+
+// Compact 把 JSON 文本 data 去除空白后追加到 dst：字符串先反转义再按 EscapeString 重新转义，
+// 因此同一字符串的不同转义写法（如 \u0041 与 A）输出相同；数值、true/false/null 原样输出，key 的顺序不变。
+// 与转码不同，Compact 按 RFC 8259 完整检查语法（分隔符缺失、尾随逗号、值之后的多余内容均为错误），出错时返回 dst 与错误。
+func Compact[S Bytes](dst []byte, data S) ([]byte, error) {
+	c := canonicalizer[S]{dst: dst}
+	return c.run(data)
+}
+
+// Canonicalize 按 RFC 8785（JSON Canonicalization Scheme）把 data 规范化后追加到 dst，用于对 JSON 计算摘要或签名：
+// 去除空白；对象成员按 key 的 UTF-16 码元排序；字符串只转义 "、\ 与控制字符（控制字符优先使用 \b \t \n \f \r）；
+// 数值按 ECMAScript Number.prototype.toString 的格式输出（如 1.0 与 1e0 均为 1，1e21 为 1e+21）。
+// 除 Compact 的语法检查外，key 重复、字符串含非法 UTF-8 或不成对的 \uD800-\uDFFF 代理（ErrLoneSurrogate）、
+// 数值超出 float64 范围时也返回错误。
+func Canonicalize[S Bytes](dst []byte, data S) ([]byte, error) {
+	c := canonicalizer[S]{dst: dst, jcs: true}
+	return c.run(data)
+}
+
+// canonicalMember 是对象成员在 dst 中的范围 [start, end) 与其反转义后的 key 在 keys 中的范围
+type canonicalMember struct {
+	start, end       int
+	keyStart, keyEnd int
+}
+
+type canonicalizer[S Bytes] struct {
+	it    Iter[S]
+	dst   []byte
+	jcs   bool
+	depth int
+	// tmp 用于字符串反转义，swap 用于重排对象成员
+	tmp  []byte
+	swap []byte
+	// members 与 keys 按对象嵌套栈式复用，每个对象只使用自己开始之后追加的部分
+	members []canonicalMember
+	keys    []byte
+}
+
+func (c *canonicalizer[S]) run(data S) ([]byte, error) {
+	c.it.Reset(data)
+	k, s := c.it.Next()
+	if err := c.value(k, s); err != nil {
+		return c.dst, err
+	}
+	if k, _ := c.it.Next(); k != EOF {
+		return c.dst, c.unexpected(k)
+	}
+	return c.dst, nil
+}
+
+// unexpected 返回在 k 处不该出现 token 时的错误。
+func (c *canonicalizer[S]) unexpected(k Kind) error {
+	switch k {
+	case EOF:
+		return io.ErrUnexpectedEOF
+	case Invalid:
+		if c.it.reason != nil {
+			return c.it.reason
+		}
+	}
+	return ErrUnexpectedToken
+}
+
+func (c *canonicalizer[S]) value(k Kind, s S) error {
+	switch k {
+	case Null, Bool:
+		c.dst = append(c.dst, s...)
+	case Number:
+		if c.jcs {
+			return c.number(s)
+		}
+		c.dst = append(c.dst, s...)
+	case String:
+		_, err := c.string(s)
+		return err
+	case Object, Array:
+		if c.depth >= maxCanonicalDepth {
+			return ErrMaxDepth
+		}
+		c.depth++
+		var err error
+		if k == Object {
+			err = c.object()
+		} else {
+			err = c.array()
+		}
+		c.depth--
+		return err
+	default:
+		return c.unexpected(k)
+	}
+	return nil
+}
+
+// string 输出规范化的字符串 token s，返回反转义后的内容（只在下一次调用 string 之前有效）。
+func (c *canonicalizer[S]) string(s S) ([]byte, error) {
+	z := s[1 : len(s)-1]
+	// RFC 8785 要求输入是合法的 I-JSON，不成对的代理不能被悄悄替换
+	var err error
+	c.tmp, err = unescapeString(c.tmp[:0], z, c.jcs)
+	if err != nil {
+		return nil, err
+	}
+	if c.jcs && !utf8.Valid(c.tmp) {
+		return nil, ErrInvalidUTF8
+	}
+	c.dst = append(c.dst, '"')
+	c.dst = escapeString(c.dst, c.tmp, !c.jcs)
+	c.dst = append(c.dst, '"')
+	return c.tmp, nil
+}
+
+// number 按 ECMAScript 的规则输出数值：[1e-6, 1e21) 内用定点表示，否则用指数表示且指数不补 0。
+func (c *canonicalizer[S]) number(s S) error {
+	x, err := strconv.ParseFloat(string(s), 64)
+	if err != nil || math.IsInf(x, 0) {
+		return ErrNumberRange
+	}
+	if x == 0 {
+		// -0 也输出 0
+		c.dst = append(c.dst, '0')
+		return nil
+	}
+	if x < 0 {
+		c.dst = append(c.dst, '-')
+		x = -x
+	}
+	if x >= 1e-6 && x < 1e21 {
+		c.dst = strconv.AppendFloat(c.dst, x, 'f', -1, 64)
+		return nil
+	}
+	start := len(c.dst)
+	c.dst = strconv.AppendFloat(c.dst, x, 'e', -1, 64)
+	// Go 的指数至少两位（1e+09），ECMAScript 不补 0（1e+9）
+	if e := bytes.IndexByte(c.dst[start:], 'e') + start; c.dst[e+2] == '0' {
+		c.dst = append(c.dst[:e+2], c.dst[e+3:]...)
+	}
+	return nil
+}
+
+func (c *canonicalizer[S]) array() error {
+	c.dst = append(c.dst, '[')
+	k, s := c.it.Next()
+	if k == ArrayClose {
+		c.dst = append(c.dst, ']')
+		return nil
+	}
+	for {
+		if err := c.value(k, s); err != nil {
+			return err
+		}
+		switch k, _ = c.it.Next(); k {
+		case Comma:
+			c.dst = append(c.dst, ',')
+			k, s = c.it.Next()
+		case ArrayClose:
+			c.dst = append(c.dst, ']')
+			return nil
+		default:
+			return c.unexpected(k)
+		}
+	}
+}
+
+func (c *canonicalizer[S]) object() error {
+	c.dst = append(c.dst, '{')
+	base, keysBase, start := len(c.members), len(c.keys), len(c.dst)
+	defer func() {
+		c.members = c.members[:base]
+		c.keys = c.keys[:keysBase]
+	}()
+
+	k, s := c.it.Next()
+	if k == ObjectClose {
+		c.dst = append(c.dst, '}')
+		return nil
+	}
+	for {
+		if k != String {
+			return c.unexpected(k)
+		}
+		m := canonicalMember{start: len(c.dst)}
+		key, err := c.string(s)
+		if err != nil {
+			return err
+		}
+		if c.jcs {
+			m.keyStart = len(c.keys)
+			c.keys = append(c.keys, key...)
+			m.keyEnd = len(c.keys)
+		}
+		if k, _ = c.it.Next(); k != Colon {
+			return c.unexpected(k)
+		}
+		c.dst = append(c.dst, ':')
+		k, s = c.it.Next()
+		if err := c.value(k, s); err != nil {
+			return err
+		}
+		m.end = len(c.dst)
+		c.members = append(c.members, m)
+
+		switch k, _ = c.it.Next(); k {
+		case Comma:
+			c.dst = append(c.dst, ',')
+			k, s = c.it.Next()
+		case ObjectClose:
+			if c.jcs {
+				if err := c.sortMembers(start, c.members[base:]); err != nil {
+					return err
+				}
+			}
+			c.dst = append(c.dst, '}')
+			return nil
+		default:
+			return c.unexpected(k)
+		}
+	}
+}
+
+// sortMembers 把从 dst[start:] 开始、以逗号分隔的对象成员 members 按 key 的 UTF-16 码元顺序重排。
+func (c *canonicalizer[S]) sortMembers(start int, members []canonicalMember) error {
+	if len(members) < 2 {
+		return nil
+	}
+	slices.SortStableFunc(members, func(a, b canonicalMember) int {
+		return compareUTF16(c.keys[a.keyStart:a.keyEnd], c.keys[b.keyStart:b.keyEnd])
+	})
+	for i := 1; i < len(members); i++ {
+		if bytes.Equal(c.keys[members[i-1].keyStart:members[i-1].keyEnd], c.keys[members[i].keyStart:members[i].keyEnd]) {
+			return ErrDuplicateKey
+		}
+	}
+	c.swap = append(c.swap[:0], c.dst[start:]...)
+	c.dst = c.dst[:start]
+	for i, m := range members {
+		if i > 0 {
+			c.dst = append(c.dst, ',')
+		}
+		c.dst = append(c.dst, c.swap[m.start-start:m.end-start]...)
+	}
+	return nil
+}
+
+// compareUTF16 按 UTF-16 码元比较两个合法的 UTF-8 字符串。与字节序不同的只有补充平面字符（代理对）与 U+E000..U+FFFF 之间的顺序。
+func compareUTF16(a, b []byte) int {
+	for len(a) > 0 && len(b) > 0 {
+		ra, na := utf8.DecodeRune(a)
+		rb, nb := utf8.DecodeRune(b)
+		if ra != rb {
+			// 高位代理相同的两个补充平面字符按低位代理比较，与按码点比较一致
+			if ka, kb := utf16Key(ra), utf16Key(rb); ka != kb {
+				return ka - kb
+			}
+			return int(ra - rb)
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return len(a) - len(b)
+}
+
+// utf16Key 返回 r 的第一个 UTF-16 码元，补充平面字符为其高位代理。
+func utf16Key(r rune) int {
+	if r >= 0x10000 {
+		hi, _ := utf16.EncodeRune(r)
+		return int(hi)
+	}
+	return int(r)
+}
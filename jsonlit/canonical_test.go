@@ -0,0 +1,159 @@
+package jsonlit
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr error
+	}{
+		{input: " { \"a\" : [ 1 , 2.50 , -0 ] ,\n\t\"b\" : { } , \"c\": [ ] } ", want: `{"a":[1,2.50,-0],"b":{},"c":[]}`},
+		{input: `"\u0041\/\u00e9\ud83d\ude00\t"`, want: `"A\/é😀\t"`},
+		{input: `{"z":null,"a":true,"m":false}`, want: `{"z":null,"a":true,"m":false}`},
+		{input: `{"a" 1}`, wantErr: ErrUnexpectedToken},
+		{input: `{"a":1,}`, wantErr: ErrUnexpectedToken},
+		{input: `[1 2]`, wantErr: ErrUnexpectedToken},
+		{input: `[1,]`, wantErr: ErrUnexpectedToken},
+		{input: `{1:2}`, wantErr: ErrUnexpectedToken},
+		{input: `1 2`, wantErr: ErrUnexpectedToken},
+		{input: `{"a":[1`, wantErr: io.ErrUnexpectedEOF},
+		{input: ``, wantErr: io.ErrUnexpectedEOF},
+		{input: `["\x"]`, wantErr: ErrInvalidEscape},
+		// Compact 与 UnescapeString 一致，不成对的代理替换为 U+FFFD
+		{input: `["\ud800"]`, want: "[\"\ufffd\"]"},
+		{input: `[01]`, wantErr: ErrInvalidNumber},
+		{input: strings.Repeat("[", maxCanonicalDepth+1), wantErr: ErrMaxDepth},
+	}
+	for _, tt := range tests {
+		got, err := Compact([]byte("prefix:"), tt.input)
+		if err != tt.wantErr {
+			t.Errorf("Compact(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got) != "prefix:"+tt.want {
+			t.Errorf("Compact(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		// RFC 8785 3.2.2
+		{
+			name: "rfc8785",
+			input: `{
+  "numbers": [333333333.33333329, 1E30, 4.50,
+              2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		// RFC 8785 3.2.3，按 UTF-16 码元排序
+		{
+			name: "sort_utf16",
+			input: `{
+  "\u20ac": "Euro Sign",
+  "\r": "Carriage Return",
+  "\ufb33": "Hebrew Letter Dalet With Dagesh",
+  "1": "One",
+  "\ud83d\ude00": "Emoji: Grinning Face",
+  "\u0080": "Control",
+  "\u00f6": "Latin Small Letter O With Diaeresis"
+}`,
+			want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{name: "nested", input: `{"b":{"y":[{"d":1,"c":2}],"x":0},"a":-0.0}`, want: `{"a":0,"b":{"x":0,"y":[{"c":2,"d":1}]}}`},
+		{name: "escaped_key_order", input: `{"\u0062":1,"a":2}`, want: `{"a":2,"b":1}`},
+		{name: "duplicate", input: `{"a":1,"\u0061":2}`, wantErr: ErrDuplicateKey},
+		{name: "range", input: `[1e400]`, wantErr: ErrNumberRange},
+		{name: "bad_utf8", input: "[\"\xff\"]", wantErr: ErrInvalidUTF8},
+		// 不成对的代理不是合法的 I-JSON，不替换为 U+FFFD
+		{name: "lone_high_surrogate", input: `["\ud800"]`, wantErr: ErrLoneSurrogate},
+		{name: "lone_low_surrogate", input: `["a\udc00b"]`, wantErr: ErrLoneSurrogate},
+		{name: "unpaired_high_surrogate", input: `["\ud83d\u0041"]`, wantErr: ErrLoneSurrogate},
+		{name: "lone_surrogate_key", input: `{"\udfff":1}`, wantErr: ErrLoneSurrogate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize(nil, []byte(tt.input))
+			if err != tt.wantErr {
+				t.Fatalf("Canonicalize() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Fatalf("Canonicalize() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalize_number(t *testing.T) {
+	// RFC 8785 附录 B 的测试向量
+	tests := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+	for _, tt := range tests {
+		input := strconv.FormatFloat(math.Float64frombits(tt.bits), 'g', -1, 64)
+		got, err := Canonicalize(nil, input)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Canonicalize(%s) = %s, %v, want %s", input, got, err, tt.want)
+		}
+	}
+}
+
+func TestCompareUTF16(t *testing.T) {
+	keys := []string{"", "\r", "1", "10", "\u0080", "ö", "€", "😀", "\U0001F600a", "😁", "\ufb33", "\uffff"}
+	for i := range keys {
+		for j := range keys {
+			got := compareUTF16([]byte(keys[i]), []byte(keys[j]))
+			if (got < 0) != (i < j) || (got == 0) != (i == j) {
+				t.Errorf("compareUTF16(%q, %q) = %d", keys[i], keys[j], got)
+			}
+		}
+	}
+}
+
+func TestCanonicalize_errorsIs(t *testing.T) {
+	if _, err := Canonicalize(nil, `{"a":nul}`); !errors.Is(err, ErrInvalidLiteral) {
+		t.Fatal(err)
+	}
+}
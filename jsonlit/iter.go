@@ -25,6 +25,14 @@ const (
 type Iter[S Bytes] struct {
 	s S
 	p int
+	// tok 是最近一个 token 的起始偏移，reason 是它为 Invalid 时的原因
+	tok    int
+	reason error
+	// relaxed 开启宽松模式，tmp 保存宽松模式下改写为标准形式的 token，见 SetRelaxed；
+	// last 是宽松模式下最近一个 token 的种类，用于检查逗号的位置
+	relaxed bool
+	tmp     []byte
+	last    Kind
 }
 
 func NewIter[S Bytes](s S) *Iter[S] {
@@ -36,6 +44,38 @@ func NewIter[S Bytes](s S) *Iter[S] {
 func (it *Iter[S]) Reset(data S) {
 	it.s = data
 	it.p = 0
+	it.tok = 0
+	it.reason = nil
+	it.last = Invalid
+}
+
+// Offset 返回下一个未读字节的偏移。
+func (it *Iter[S]) Offset() int {
+	return min(it.p, len(it.s))
+}
+
+// TokenOffset 返回最近一次 Next 返回的 token 的起始偏移。
+func (it *Iter[S]) TokenOffset() int {
+	return it.tok
+}
+
+// Reason 返回最近一次 Next 返回的 Invalid token 的原因，如 ErrUnterminatedString，其它 token 返回 nil。
+func (it *Iter[S]) Reason() error {
+	return it.reason
+}
+
+// Position 返回偏移 offset 在输入中的行列位置。
+func (it *Iter[S]) Position(offset int) Position {
+	line, col := LineCol(it.s, offset)
+	return Position{Offset: int64(offset), Line: line, Column: col}
+}
+
+// Len 返回尚未读取的字节数。
+func (it *Iter[S]) Len() int {
+	if it.p >= len(it.s) {
+		return 0
+	}
+	return len(it.s) - it.p
 }
 
 func (it *Iter[S]) EOF() bool {
@@ -45,21 +85,22 @@ func (it *Iter[S]) EOF() bool {
 func (it *Iter[S]) nextString() (Kind, S) {
 	b := it.p
 	p := it.p + 1
-	for p < len(it.s) {
-		c := it.s[p]
-		if c == '\\' {
+	for {
+		p = indexQuoteOrBackslash(it.s, p)
+		if p >= len(it.s) {
+			break
+		}
+		if it.s[p] == '\\' {
 			// 跳过转义字符的下一个字符，避免把 \" 误判为闭合引号，
 			// 也避免 \\<引号> 把闭合引号当成被转义的字符。
 			p += 2
 			continue
 		}
-		if c == '"' {
-			it.p = p + 1
-			return String, it.s[b:it.p]
-		}
-		p++
+		it.p = p + 1
+		return String, it.s[b:it.p]
 	}
 	it.p = p
+	it.reason = ErrUnterminatedString
 	return Invalid, it.s[b:]
 }
 
@@ -68,12 +109,17 @@ func (it *Iter[S]) nextNumber() (Kind, S) {
 	p := it.p + 1
 	for p < len(it.s) {
 		c := it.s[p]
-		if !isdigit(c) && c != '.' && c != '-' && c != 'e' && c != 'E' {
+		if !isnumberchar(c) {
 			break
 		}
 		p++
 	}
 	it.p = p
+	// 范围内的字符不构成合法的 number（如 1-2、01、1e）时整体作为 Invalid
+	if ClassifyNumber(it.s[b:p]) == NotNumber {
+		it.reason = ErrInvalidNumber
+		return Invalid, it.s[b:p]
+	}
 	return Number, it.s[b:p]
 }
 
@@ -95,21 +141,34 @@ func (it *Iter[S]) expect(expected string, kind Kind) (Kind, S) {
 		// See: https://www.go101.org/article/string.html#conversion-optimizations
 		kind = Invalid
 	}
+	if kind == Invalid {
+		it.reason = ErrInvalidLiteral
+	}
 	it.p = e
 	return kind, it.s[p:e]
 }
 
 func (it *Iter[S]) Next() (Kind, S) {
+	if it.relaxed {
+		return it.nextRelaxed()
+	}
 	p := it.p
 	for p < len(it.s) && iswhitespace(it.s[p]) {
 		p++
 	}
+	it.reason = nil
 	if p >= len(it.s) {
+		it.tok = len(it.s)
 		return EOF, it.s[len(it.s):]
 	}
 	it.p = p
+	it.tok = p
+	return it.token()
+}
 
-	c := it.s[p]
+// token 读取从 it.p 开始的一个 token，it.p 处不是空白。
+func (it *Iter[S]) token() (Kind, S) {
+	c := it.s[it.p]
 	switch c {
 	case 'n':
 		return it.expect("null", Null)
@@ -136,6 +195,7 @@ func (it *Iter[S]) Next() (Kind, S) {
 			return it.nextNumber()
 		}
 	}
+	it.reason = ErrInvalidChar
 	return it.consume(Invalid)
 }
 
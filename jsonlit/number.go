@@ -0,0 +1,65 @@
+package jsonlit
+
+// NumberClass 是 number 字面量的分类，见 ClassifyNumber。
+type NumberClass uint8
+
+const (
+	NotNumber NumberClass = iota
+	// IntegerNumber 不含小数部分与指数部分，如 -12
+	IntegerNumber
+	// FloatNumber 含小数部分或指数部分，如 1.0、1e2
+	FloatNumber
+)
+
+// ClassifyNumber 按 RFC 8259 的 number 语法检查 s，返回其分类，s 不是合法的 number 时返回 NotNumber。
+// 语法为 -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?，不接受前导 +、多余的前导 0 与不完整的小数或指数。
+func ClassifyNumber[S Bytes](s S) NumberClass {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && '1' <= s[i] && s[i] <= '9':
+		i = skipDigits(s, i+1)
+	default:
+		return NotNumber
+	}
+	class := IntegerNumber
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i >= len(s) || !isdigit(s[i]) {
+			return NotNumber
+		}
+		i = skipDigits(s, i+1)
+		class = FloatNumber
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i >= len(s) || !isdigit(s[i]) {
+			return NotNumber
+		}
+		i = skipDigits(s, i+1)
+		class = FloatNumber
+	}
+	if i != len(s) {
+		return NotNumber
+	}
+	return class
+}
+
+func skipDigits[S Bytes](s S, i int) int {
+	for i < len(s) && isdigit(s[i]) {
+		i++
+	}
+	return i
+}
+
+// isnumberchar 判断 c 是否可能出现在 number 字面量中，词法分析按此确定 token 的范围后再检查语法。
+func isnumberchar(c byte) bool {
+	return isdigit(c) || c == '.' || c == '-' || c == '+' || c == 'e' || c == 'E'
+}
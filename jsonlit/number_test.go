@@ -0,0 +1,68 @@
+package jsonlit
+
+import "testing"
+
+func TestClassifyNumber(t *testing.T) {
+	tests := []struct {
+		s    string
+		want NumberClass
+	}{
+		{s: "0", want: IntegerNumber},
+		{s: "-0", want: IntegerNumber},
+		{s: "123", want: IntegerNumber},
+		{s: "-9223372036854775808", want: IntegerNumber},
+		{s: "1.0", want: FloatNumber},
+		{s: "-0.5", want: FloatNumber},
+		{s: "1e2", want: FloatNumber},
+		{s: "1E+2", want: FloatNumber},
+		{s: "1.5e-10", want: FloatNumber},
+		{s: "", want: NotNumber},
+		{s: "-", want: NotNumber},
+		{s: "+1", want: NotNumber},
+		{s: "01", want: NotNumber},
+		{s: "-01", want: NotNumber},
+		{s: "1-2", want: NotNumber},
+		{s: "--3", want: NotNumber},
+		{s: "1.2.3", want: NotNumber},
+		{s: "1.", want: NotNumber},
+		{s: ".5", want: NotNumber},
+		{s: "1e", want: NotNumber},
+		{s: "1e+", want: NotNumber},
+		{s: "1e2.5", want: NotNumber},
+		{s: "1x", want: NotNumber},
+	}
+	for _, tt := range tests {
+		if got := ClassifyNumber(tt.s); got != tt.want {
+			t.Errorf("ClassifyNumber(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+		if got := ClassifyNumber([]byte(tt.s)); got != tt.want {
+			t.Errorf("ClassifyNumber([]byte(%q)) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIter_number(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  Kind
+		tok   string
+	}{
+		{input: "1e+2,", kind: Number, tok: "1e+2"},
+		{input: "-0.5]", kind: Number, tok: "-0.5"},
+		{input: "1-2,", kind: Invalid, tok: "1-2"},
+		{input: "--3", kind: Invalid, tok: "--3"},
+		{input: "1.2.3}", kind: Invalid, tok: "1.2.3"},
+		{input: "01", kind: Invalid, tok: "01"},
+		{input: "1e ", kind: Invalid, tok: "1e"},
+	}
+	for _, tt := range tests {
+		it := NewIter(tt.input)
+		k, s := it.Next()
+		if k != tt.kind || s != tt.tok {
+			t.Errorf("%q: Next() = %v %q, want %v %q", tt.input, k, s, tt.kind, tt.tok)
+		}
+		if k == Invalid && it.Reason() != ErrInvalidNumber {
+			t.Errorf("%q: Reason() = %v", tt.input, it.Reason())
+		}
+	}
+}
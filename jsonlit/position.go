@@ -0,0 +1,41 @@
+package jsonlit
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Invalid token 的原因，见 Iter.Reason 与 ReaderIter.Reason。
+var (
+	ErrUnterminatedString = errors.New("unterminated string")
+	ErrInvalidLiteral     = errors.New("invalid literal")
+	ErrInvalidNumber      = errors.New("invalid number")
+	ErrInvalidChar        = errors.New("invalid character")
+)
+
+// Position 是输入中的位置，Line 与 Column 从 1 开始，Column 按字节计算。Line 为 0 表示行列未知。
+type Position struct {
+	Offset int64
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line <= 0 {
+		return "offset " + strconv.FormatInt(p.Offset, 10)
+	}
+	return "line " + strconv.Itoa(p.Line) + " col " + strconv.Itoa(p.Column)
+}
+
+// LineCol 返回 s 中第 offset 字节所在的行与列，均从 1 开始，offset 超出 s 时按 s 的末尾计算。
+func LineCol[S Bytes](s S, offset int) (line, col int) {
+	offset = max(min(offset, len(s)), 0)
+	line, start := 1, 0
+	for i := 0; i < offset; i++ {
+		if s[i] == '\n' {
+			line++
+			start = i + 1
+		}
+	}
+	return line, offset - start + 1
+}
@@ -0,0 +1,84 @@
+package jsonlit
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineCol(t *testing.T) {
+	const s = "ab\ncd\n\nef"
+	tests := []struct {
+		offset    int
+		line, col int
+	}{
+		{offset: 0, line: 1, col: 1},
+		{offset: 2, line: 1, col: 3},
+		{offset: 3, line: 2, col: 1},
+		{offset: 6, line: 3, col: 1},
+		{offset: 8, line: 4, col: 2},
+		{offset: 100, line: 4, col: 3},
+		{offset: -1, line: 1, col: 1},
+	}
+	for _, tt := range tests {
+		if line, col := LineCol(s, tt.offset); line != tt.line || col != tt.col {
+			t.Errorf("LineCol(%d) = %d:%d, want %d:%d", tt.offset, line, col, tt.line, tt.col)
+		}
+	}
+}
+
+func TestIter_Reason(t *testing.T) {
+	tests := []struct {
+		input  string
+		offset int
+		pos    string
+		reason error
+	}{
+		{input: "{\n  \"a\": \"xyz", offset: 9, pos: "line 2 col 8", reason: ErrUnterminatedString},
+		{input: "[1,\n\n nul]", offset: 6, pos: "line 3 col 2", reason: ErrInvalidLiteral},
+		{input: "[true, fals", offset: 7, pos: "line 1 col 8", reason: ErrInvalidLiteral},
+		{input: "[1, -]", offset: 4, pos: "line 1 col 5", reason: ErrInvalidNumber},
+		{input: "{\"a\":\r\n@}", offset: 7, pos: "line 2 col 1", reason: ErrInvalidChar},
+		{input: "[1, 2]\n", offset: 7, pos: "line 2 col 1", reason: nil},
+	}
+	for _, tt := range tests {
+		for _, size := range []int{1, 3, 0} {
+			it := NewIter(tt.input)
+			rit := NewReaderIter(iotest.OneByteReader(strings.NewReader(tt.input)), size)
+			for {
+				k, _ := it.Next()
+				rk, _ := rit.Next()
+				if k != rk {
+					t.Fatalf("%q: ReaderIter token %v, want %v", tt.input, rk, k)
+				}
+				if k == Invalid || k == EOF {
+					break
+				}
+			}
+			if it.TokenOffset() != tt.offset || it.Reason() != tt.reason || it.Position(it.TokenOffset()).String() != tt.pos {
+				t.Errorf("%q: Iter at %d %v %v, want %d %s %v", tt.input, it.TokenOffset(), it.Position(it.TokenOffset()), it.Reason(), tt.offset, tt.pos, tt.reason)
+			}
+			if rit.TokenOffset() != int64(tt.offset) || rit.Reason() != tt.reason || rit.Position(rit.TokenOffset()).String() != tt.pos {
+				t.Errorf("%q size=%d: ReaderIter at %d %v %v, want %d %s %v", tt.input, size, rit.TokenOffset(), rit.Position(rit.TokenOffset()), rit.Reason(), tt.offset, tt.pos, tt.reason)
+			}
+		}
+	}
+}
+
+func TestReaderIter_Position(t *testing.T) {
+	input := strings.Repeat("[1,\n  2],\n", 50) + "  x"
+	it := NewReaderIter(iotest.OneByteReader(strings.NewReader(input)), 4)
+	for {
+		k, _ := it.Next()
+		if k == Invalid || k == EOF {
+			break
+		}
+	}
+	want := Position{Offset: int64(len(input) - 1), Line: 101, Column: 3}
+	if pos := it.Position(it.TokenOffset()); pos != want {
+		t.Fatalf("Position() = %+v, want %+v", pos, want)
+	}
+	if pos := it.Position(0); pos.Line != 0 {
+		t.Fatalf("Position(0) = %+v, want unknown line", pos)
+	}
+}
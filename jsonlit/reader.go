@@ -0,0 +1,286 @@
+package jsonlit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const defaultReaderBufferSize = 4096
+
+var ErrTokenTooLarge = errors.New("token exceeds max window size")
+
+// ReaderIter 从 io.Reader 增量读取 JSON 并分词，只缓冲当前 token 所需的窗口。
+// 跨越窗口边界的 token 会在补充数据后完整返回，窗口只在单个 token 超出其大小时增长。
+// Next 返回的切片只在下一次调用 Next/EOF 之前有效。
+type ReaderIter struct {
+	r   io.Reader
+	buf []byte
+	p   int
+	off int64 // buf[0] 在输入中的偏移
+	err error
+	// tok 是最近一个 token 在输入中的起始偏移，reason 是它为 Invalid 时的原因
+	tok    int64
+	reason error
+	// line 是 off 之前的换行数，lineOff 是 off 所在行的起始偏移，用于计算行列
+	line    int
+	lineOff int64
+	// maxWindow 是窗口可增长到的最大字节数，0 表示不限制
+	maxWindow int
+}
+
+// NewReaderIter 创建一个从 r 读取的 ReaderIter，size 为初始窗口大小，size <= 0 时使用 4096。
+func NewReaderIter(r io.Reader, size int) *ReaderIter {
+	if size <= 0 {
+		size = defaultReaderBufferSize
+	}
+	return &ReaderIter{
+		r:   r,
+		buf: make([]byte, 0, size),
+	}
+}
+
+func (it *ReaderIter) Reset(r io.Reader) {
+	it.r = r
+	it.buf = it.buf[:0]
+	it.p = 0
+	it.off = 0
+	it.err = nil
+	it.tok = 0
+	it.reason = nil
+	it.line = 0
+	it.lineOff = 0
+}
+
+// SetMaxWindow 限制窗口可增长到的最大字节数，n <= 0 表示不限制（默认）。窗口不会缩小到初始大小以下。
+// 单个 token 超出窗口时不再读取，Next 返回 Invalid，Reason 与 Err 均为 ErrTokenTooLarge，
+// 因此输入中的超长字符串不会被整体读入内存。
+func (it *ReaderIter) SetMaxWindow(n int) {
+	it.maxWindow = max(n, 0)
+}
+
+// Err 返回读取时遇到的错误，读到输入末尾不算错误。
+func (it *ReaderIter) Err() error {
+	if it.err == io.EOF {
+		return nil
+	}
+	return it.err
+}
+
+// Offset 返回下一个未读字节在输入中的偏移。
+func (it *ReaderIter) Offset() int64 {
+	return it.off + int64(it.p)
+}
+
+// TokenOffset 返回最近一次 Next 返回的 token 在输入中的起始偏移。
+func (it *ReaderIter) TokenOffset() int64 {
+	return it.tok
+}
+
+// Reason 返回最近一次 Next 返回的 Invalid token 的原因，如 ErrUnterminatedString，其它 token 返回 nil。
+func (it *ReaderIter) Reason() error {
+	return it.reason
+}
+
+// Position 返回偏移 offset 在输入中的行列位置。窗口只保留当前 token 之后的内容，
+// offset 早于窗口（已被丢弃）时行列未知，只返回 Offset。
+func (it *ReaderIter) Position(offset int64) Position {
+	if offset < it.off {
+		return Position{Offset: offset}
+	}
+	window := it.buf[:min(offset-it.off, int64(len(it.buf)))]
+	line := it.line + bytes.Count(window, []byte{'\n'}) + 1
+	if i := bytes.LastIndexByte(window, '\n'); i >= 0 {
+		return Position{Offset: offset, Line: line, Column: int(offset-it.off) - i}
+	}
+	return Position{Offset: offset, Line: line, Column: int(offset-it.lineOff) + 1}
+}
+
+// fill 把 buf[p:] 移到窗口开头后读取更多数据，没有读到数据时返回 false。
+// 调用方应使用相对 p 的下标，fill 之后 p 为 0。窗口已满且不能再增长时 err 为 ErrTokenTooLarge。
+func (it *ReaderIter) fill() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.p > 0 {
+		if d := it.buf[:it.p]; bytes.IndexByte(d, '\n') >= 0 {
+			it.line += bytes.Count(d, []byte{'\n'})
+			it.lineOff = it.off + int64(bytes.LastIndexByte(d, '\n')) + 1
+		}
+		n := copy(it.buf, it.buf[it.p:])
+		it.buf = it.buf[:n]
+		it.off += int64(it.p)
+		it.p = 0
+	}
+	if len(it.buf) == cap(it.buf) {
+		size := 2 * cap(it.buf)
+		if it.maxWindow > 0 && size > it.maxWindow {
+			size = it.maxWindow
+		}
+		if size <= cap(it.buf) {
+			it.err = ErrTokenTooLarge
+			return false
+		}
+		newbuf := make([]byte, len(it.buf), size)
+		copy(newbuf, it.buf)
+		it.buf = newbuf
+	}
+	for retry := 0; retry < 100; retry++ {
+		n, err := it.r.Read(it.buf[len(it.buf):cap(it.buf)])
+		it.buf = it.buf[:len(it.buf)+n]
+		if err != nil {
+			it.err = err
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
+	it.err = io.ErrNoProgress
+	return false
+}
+
+func (it *ReaderIter) EOF() bool {
+	if it.p < len(it.buf) {
+		return false
+	}
+	return !it.fill()
+}
+
+func (it *ReaderIter) nextString() (Kind, []byte) {
+	k := 1
+	for {
+		if it.p+k >= len(it.buf) {
+			if !it.fill() {
+				break
+			}
+			continue
+		}
+		k = indexQuoteOrBackslash(it.buf, it.p+k) - it.p
+		if it.p+k >= len(it.buf) {
+			continue
+		}
+		if it.buf[it.p+k] == '\\' {
+			k += 2
+			continue
+		}
+		b := it.p
+		it.p += k + 1
+		return String, it.buf[b:it.p]
+	}
+	b := it.p
+	it.p = len(it.buf)
+	it.reason = it.fillReason(ErrUnterminatedString)
+	return Invalid, it.buf[b:]
+}
+
+// fillReason 返回 fill 失败导致 token 不完整时的原因：窗口不能再增长时为 ErrTokenTooLarge，否则为 reason。
+func (it *ReaderIter) fillReason(reason error) error {
+	if it.err == ErrTokenTooLarge {
+		return ErrTokenTooLarge
+	}
+	return reason
+}
+
+func (it *ReaderIter) nextNumber() (Kind, []byte) {
+	k := 1
+	for {
+		if it.p+k >= len(it.buf) {
+			if !it.fill() {
+				break
+			}
+			continue
+		}
+		c := it.buf[it.p+k]
+		if !isnumberchar(c) {
+			break
+		}
+		k++
+	}
+	b := it.p
+	it.p += k
+	if it.err == ErrTokenTooLarge {
+		it.reason = ErrTokenTooLarge
+		return Invalid, it.buf[b:it.p]
+	}
+	// 范围内的字符不构成合法的 number（如 1-2、01、1e）时整体作为 Invalid
+	if ClassifyNumber(it.buf[b:it.p]) == NotNumber {
+		it.reason = ErrInvalidNumber
+		return Invalid, it.buf[b:it.p]
+	}
+	return Number, it.buf[b:it.p]
+}
+
+func (it *ReaderIter) consume(kind Kind) (Kind, []byte) {
+	p := it.p
+	it.p++
+	return kind, it.buf[p : p+1]
+}
+
+func (it *ReaderIter) expect(expected string, kind Kind) (Kind, []byte) {
+	for len(it.buf)-it.p < len(expected) {
+		if !it.fill() {
+			break
+		}
+	}
+	p := it.p
+	e := p + len(expected)
+	if e > len(it.buf) {
+		e = len(it.buf)
+		kind = Invalid
+	} else if string(it.buf[p:e]) != expected {
+		kind = Invalid
+	}
+	if kind == Invalid {
+		it.reason = it.fillReason(ErrInvalidLiteral)
+	}
+	it.p = e
+	return kind, it.buf[p:e]
+}
+
+func (it *ReaderIter) Next() (Kind, []byte) {
+	it.reason = nil
+	for {
+		for it.p < len(it.buf) && iswhitespace(it.buf[it.p]) {
+			it.p++
+		}
+		if it.p < len(it.buf) {
+			break
+		}
+		if !it.fill() {
+			it.tok = it.off + int64(len(it.buf))
+			return EOF, it.buf[len(it.buf):]
+		}
+	}
+	it.tok = it.off + int64(it.p)
+
+	c := it.buf[it.p]
+	switch c {
+	case 'n':
+		return it.expect("null", Null)
+	case 't':
+		return it.expect("true", Bool)
+	case 'f':
+		return it.expect("false", Bool)
+	case '"':
+		return it.nextString()
+	case '{':
+		return it.consume(Object)
+	case '}':
+		return it.consume(ObjectClose)
+	case '[':
+		return it.consume(Array)
+	case ']':
+		return it.consume(ArrayClose)
+	case ',':
+		return it.consume(Comma)
+	case ':':
+		return it.consume(Colon)
+	default:
+		if isdigit(c) || c == '-' {
+			return it.nextNumber()
+		}
+	}
+	it.reason = ErrInvalidChar
+	return it.consume(Invalid)
+}
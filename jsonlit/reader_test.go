@@ -0,0 +1,109 @@
+package jsonlit
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReaderIter(t *testing.T) {
+	inputs := []string{
+		"{\"animals\":{\"dog\":[{\"name\":\"Rufus\",\"age\":15,\"is_male\":true},{\"name\":\"Marty\",\"age\":null,\"is_male\":false}]}}",
+		`  [ -1.5e10 , "a\\" , "\"" , "C:\\dir\\" , 1234567890 ]  `,
+		`{"long":"` + strings.Repeat("abc\\n", 100) + `"}`,
+		`"unterminated`,
+		`nul`,
+		`tru`,
+		`@`,
+		``,
+		`   `,
+	}
+	for _, input := range inputs {
+		for _, size := range []int{1, 2, 3, 7, 0} {
+			want := NewIter(input)
+			got := NewReaderIter(iotest.OneByteReader(strings.NewReader(input)), size)
+			for {
+				k1, s1 := want.Next()
+				k2, s2 := got.Next()
+				if k1 != k2 || s1 != string(s2) {
+					t.Fatalf("input=%q size=%d: got %v %q, want %v %q", input, size, k2, s2, k1, s1)
+				}
+				if k1 != EOF && want.EOF() != got.EOF() {
+					t.Fatalf("input=%q size=%d: EOF mismatch", input, size)
+				}
+				if k1 == EOF {
+					break
+				}
+			}
+			if got.Err() != nil {
+				t.Fatal(got.Err())
+			}
+		}
+	}
+}
+
+func TestReaderIter_Err(t *testing.T) {
+	readErr := errors.New("read failed")
+	it := NewReaderIter(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader(`{"a":1}`))), 0)
+	k, _ := it.Next()
+	if k != Object {
+		t.Fatal(k)
+	}
+	k, _ = it.Next()
+	if k != EOF {
+		t.Fatal(k)
+	}
+	if it.Err() != iotest.ErrTimeout {
+		t.Fatal(it.Err())
+	}
+
+	it.Reset(iotest.ErrReader(readErr))
+	if k, _ := it.Next(); k != EOF || it.Err() != readErr {
+		t.Fatal(k, it.Err())
+	}
+}
+
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestReaderIter_SetMaxWindow(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason error
+	}{
+		{input: `["` + strings.Repeat("a", 40) + `",1]`},
+		{input: `["` + strings.Repeat("a", 1<<20) + `"]`, reason: ErrTokenTooLarge},
+		{input: `[` + strings.Repeat("1", 1<<20) + `]`, reason: ErrTokenTooLarge},
+	}
+	for _, tt := range tests {
+		r := &countReader{r: strings.NewReader(tt.input)}
+		it := NewReaderIter(r, 16)
+		it.SetMaxWindow(64)
+		var reason error
+		for {
+			k, _ := it.Next()
+			if k == Invalid {
+				reason = it.Reason()
+			}
+			if k == EOF || k == Invalid {
+				break
+			}
+		}
+		if reason != tt.reason || it.Err() != tt.reason {
+			t.Fatalf("reason = %v, Err() = %v, want %v", reason, it.Err(), tt.reason)
+		}
+		if r.n > 128 && tt.reason != nil {
+			t.Fatalf("read %d bytes", r.n)
+		}
+	}
+}
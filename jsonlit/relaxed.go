@@ -0,0 +1,220 @@
+package jsonlit
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrUnterminatedComment = errors.New("unterminated comment")
+	ErrUnexpectedComma     = errors.New("unexpected comma")
+)
+
+// SetRelaxed 开启或关闭宽松模式，用于人工编写的配置等输入。宽松模式接受 JSON5 的以下扩展：
+//
+//   - `//` 行注释与 `/* */` 块注释，按空白处理
+//   - 对象与数组的尾随逗号，如 `[1,2,]`；与 JSON5 一致，逗号之前必须有成员，`[,]`、`{,}` 与 `[1,,]` 不合法
+//   - 单引号字符串，如 `'a"b'`
+//   - 不带引号的 key（ASCII 标识符，如 `name: 1`）
+//   - 十六进制整数，如 `0x1F`、`-0x10`
+//
+// 单引号字符串与不带引号的 key 被改写为双引号字符串，十六进制整数被改写为十进制，尾随逗号被丢弃，
+// 因此 Next 返回的 token 种类与内容都和标准 JSON 相同，消费方无需区分。改写后的 token 保存在内部缓冲中，
+// 只在下一次调用 Next 之前有效；S 为 string 时改写会产生分配。
+func (it *Iter[S]) SetRelaxed(relaxed bool) {
+	it.relaxed = relaxed
+}
+
+func (it *Iter[S]) nextRelaxed() (Kind, S) {
+	k, s := it.relaxedToken()
+	it.last = k
+	return k, s
+}
+
+func (it *Iter[S]) relaxedToken() (Kind, S) {
+	it.reason = nil
+	if !it.skipSpace() {
+		it.tok = it.p
+		it.reason = ErrUnterminatedComment
+		b := it.p
+		it.p = len(it.s)
+		return Invalid, it.s[b:]
+	}
+	it.tok = it.p
+	if it.p >= len(it.s) {
+		return EOF, it.s[len(it.s):]
+	}
+
+	c := it.s[it.p]
+	switch {
+	case c == ',':
+		// 逗号必须跟在成员之后，紧跟在 {、[ 或另一个逗号之后时不合法
+		if it.last == Object || it.last == Array || it.last == Comma {
+			it.p++
+			it.reason = ErrUnexpectedComma
+			return Invalid, it.s[it.tok:it.p]
+		}
+		// 逗号后（跳过空白与注释）紧跟 } 或 ] 时是尾随逗号，直接返回闭合符号
+		comma := it.p
+		it.p++
+		if it.skipSpace() && it.p < len(it.s) && (it.s[it.p] == '}' || it.s[it.p] == ']') {
+			it.tok = it.p
+			return it.token()
+		}
+		it.p = comma
+		return it.consume(Comma)
+	case c == '\'':
+		return it.nextSingleQuoted()
+	case isidentstart(c):
+		return it.nextIdent()
+	case c == '0' || c == '-':
+		if k, s, ok := it.nextHex(); ok {
+			return k, s
+		}
+	}
+	return it.token()
+}
+
+// skipSpace 跳过空白与注释，块注释未闭合时返回 false，此时 it.p 停在注释开头。
+func (it *Iter[S]) skipSpace() bool {
+	p := it.p
+	for p < len(it.s) {
+		c := it.s[p]
+		if iswhitespace(c) {
+			p++
+			continue
+		}
+		if c != '/' || p+1 >= len(it.s) {
+			break
+		}
+		if it.s[p+1] == '/' {
+			p += 2
+			for p < len(it.s) && it.s[p] != '\n' {
+				p++
+			}
+			continue
+		}
+		if it.s[p+1] == '*' {
+			e := p + 2
+			for e+1 < len(it.s) && (it.s[e] != '*' || it.s[e+1] != '/') {
+				e++
+			}
+			if e+1 >= len(it.s) {
+				it.p = p
+				return false
+			}
+			p = e + 2
+			continue
+		}
+		break
+	}
+	it.p = p
+	return true
+}
+
+// nextSingleQuoted 把单引号字符串改写为双引号字符串：内容中的 " 转义为 \"，\' 还原为 '，其它转义保持不变。
+func (it *Iter[S]) nextSingleQuoted() (Kind, S) {
+	b := it.p
+	tmp := append(it.tmp[:0], '"')
+	for p := b + 1; p < len(it.s); p++ {
+		c := it.s[p]
+		switch c {
+		case '\'':
+			it.p = p + 1
+			it.tmp = append(tmp, '"')
+			return String, S(it.tmp)
+		case '"':
+			tmp = append(tmp, '\\', '"')
+		case '\\':
+			if p+1 >= len(it.s) {
+				break
+			}
+			p++
+			if it.s[p] == '\'' {
+				tmp = append(tmp, '\'')
+			} else {
+				tmp = append(tmp, '\\', it.s[p])
+			}
+		default:
+			tmp = append(tmp, c)
+		}
+	}
+	it.tmp = tmp
+	it.p = len(it.s)
+	it.reason = ErrUnterminatedString
+	return Invalid, it.s[b:]
+}
+
+// nextIdent 读取一个标识符：其后（跳过空白与注释）是 : 时作为 key 改写为双引号字符串，
+// 否则只能是 true、false 或 null。
+func (it *Iter[S]) nextIdent() (Kind, S) {
+	b := it.p
+	e := b + 1
+	for e < len(it.s) && isidentchar(it.s[e]) {
+		e++
+	}
+	it.p = e
+	ok := it.skipSpace() && it.p < len(it.s) && it.s[it.p] == ':'
+	it.p = e
+	if ok {
+		it.tmp = append(append(append(it.tmp[:0], '"'), it.s[b:e]...), '"')
+		return String, S(it.tmp)
+	}
+	switch string(it.s[b:e]) {
+	case "true", "false":
+		return Bool, it.s[b:e]
+	case "null":
+		return Null, it.s[b:e]
+	}
+	it.reason = ErrInvalidLiteral
+	return Invalid, it.s[b:e]
+}
+
+// nextHex 读取 0x 开头（可带 -）的十六进制整数并改写为十进制，不是十六进制整数时返回 ok=false 且不移动位置。
+func (it *Iter[S]) nextHex() (Kind, S, bool) {
+	b := it.p
+	p := b
+	if it.s[p] == '-' {
+		p++
+	}
+	if p+1 >= len(it.s) || it.s[p] != '0' || (it.s[p+1] != 'x' && it.s[p+1] != 'X') {
+		return 0, it.s[:0], false
+	}
+	p += 2
+	var x uint64
+	digits := 0
+	overflow := false
+	for ; p < len(it.s); p++ {
+		v, ok := hexVal(it.s[p])
+		if !ok {
+			break
+		}
+		if x>>60 != 0 {
+			overflow = true
+		}
+		x = x<<4 | uint64(v)
+		digits++
+	}
+	it.p = p
+	if digits == 0 || overflow || (p < len(it.s) && (isnumberchar(it.s[p]) || isidentchar(it.s[p]))) {
+		for it.p < len(it.s) && (isnumberchar(it.s[it.p]) || isidentchar(it.s[it.p])) {
+			it.p++
+		}
+		it.reason = ErrInvalidNumber
+		return Invalid, it.s[b:it.p], true
+	}
+	tmp := it.tmp[:0]
+	if it.s[b] == '-' {
+		tmp = append(tmp, '-')
+	}
+	it.tmp = strconv.AppendUint(tmp, x, 10)
+	return Number, S(it.tmp), true
+}
+
+func isidentstart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$'
+}
+
+func isidentchar(c byte) bool {
+	return isidentstart(c) || isdigit(c)
+}
@@ -0,0 +1,74 @@
+package jsonlit
+
+import (
+	"strings"
+	"testing"
+)
+
+func relaxedTokens(input string) (string, error) {
+	it := NewIter([]byte(input))
+	it.SetRelaxed(true)
+	var toks []string
+	for {
+		k, s := it.Next()
+		if k == EOF {
+			return strings.Join(toks, " "), nil
+		}
+		if k == Invalid {
+			return strings.Join(toks, " "), it.Reason()
+		}
+		toks = append(toks, string(s))
+	}
+}
+
+func TestIter_SetRelaxed(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		reason error
+	}{
+		{name: "line_comment", input: "// head\n{\"a\": 1 // tail\n}", want: `{ "a" : 1 }`},
+		{name: "block_comment", input: "[1, /* two,\n three */ 2]/**/", want: `[ 1 , 2 ]`},
+		{name: "trailing_comma", input: "{\"a\":[1,2,],\n}", want: `{ "a" : [ 1 , 2 ] }`},
+		{name: "trailing_comma_comment", input: "[1, // last\n]", want: `[ 1 ]`},
+		{name: "single_quoted", input: `['a"b', 'it\'s', '\n', '']`, want: `[ "a\"b" , "it's" , "\n" , "" ]`},
+		{name: "ident_key", input: "{name: 1, $x_1 : true, null: null, true:false}", want: `{ "name" : 1 , "$x_1" : true , "null" : null , "true" : false }`},
+		{name: "hex", input: "[0x1F, -0X10, 0xffffffffffffffff, 0, -1.5]", want: `[ 31 , -16 , 18446744073709551615 , 0 , -1.5 ]`},
+		{name: "division", input: "[1 / 2]", want: `[ 1`, reason: ErrInvalidChar},
+		{name: "unterminated_comment", input: "[1 /* x", want: `[ 1`, reason: ErrUnterminatedComment},
+		{name: "unterminated_single", input: "['abc", want: `[`, reason: ErrUnterminatedString},
+		{name: "bare_value", input: "[abc]", want: `[`, reason: ErrInvalidLiteral},
+		{name: "hex_overflow", input: "[0x10000000000000000]", want: `[`, reason: ErrInvalidNumber},
+		{name: "hex_empty", input: "[0x]", want: `[`, reason: ErrInvalidNumber},
+		{name: "hex_garbage", input: "[0x1g]", want: `[`, reason: ErrInvalidNumber},
+		{name: "empty_object_comma", input: "{,}", want: `{`, reason: ErrUnexpectedComma},
+		{name: "empty_array_comma", input: "[,]", want: `[`, reason: ErrUnexpectedComma},
+		{name: "leading_comma", input: "[ /* x */ ,1]", want: `[`, reason: ErrUnexpectedComma},
+		{name: "double_comma", input: "[1,,]", want: `[ 1 ,`, reason: ErrUnexpectedComma},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := relaxedTokens(tt.input)
+			if got != tt.want || reason != tt.reason {
+				t.Fatalf("tokens = %s (%v), want %s (%v)", got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+
+	sit := NewIter("{a: 'x',}")
+	sit.SetRelaxed(true)
+	var toks []string
+	for k, s := sit.Next(); k != EOF; k, s = sit.Next() {
+		toks = append(toks, s)
+	}
+	if got := strings.Join(toks, " "); got != `{ "a" : "x" }` {
+		t.Fatalf("string input tokens = %s", got)
+	}
+
+	// 默认不开启宽松模式
+	it := NewIter("// x\n1")
+	if k, _ := it.Next(); k != Invalid {
+		t.Fatalf("strict Next() = %v, want Invalid", k)
+	}
+}
@@ -0,0 +1,64 @@
+package jsonlit
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrUnexpectedToken = errors.New("unexpected token")
+	ErrMaxDepth        = errors.New("max depth exceeded")
+)
+
+// SkipValue 读取下一个完整的值（对象、数组或标量），返回其在输入中的原文，可用于透传字段、日志等需要原始 JSON 的场景。
+// 对象与数组按括号嵌套完整读取，字符串中的括号与转义不影响嵌套。只检查括号匹配与 token 合法性，
+// 不检查逗号与冒号的位置（与转码对未知字段的处理一致）。maxDepth > 0 时限制值内对象与数组的嵌套层数，超出时返回 ErrMaxDepth。
+// 输入在值结束前结束时返回 io.ErrUnexpectedEOF；遇到不合法的 token 时返回其原因（见 Reason），括号不匹配时返回 ErrUnexpectedToken。
+func (it *Iter[S]) SkipValue(maxDepth int) (S, error) {
+	lead, _ := it.Next()
+	return it.SkipFrom(lead, maxDepth)
+}
+
+// SkipFrom 与 SkipValue 相同，但值的第一个 token 已由 Next 读出，lead 为其种类。
+func (it *Iter[S]) SkipFrom(lead Kind, maxDepth int) (S, error) {
+	b := it.tok
+	var stackBuf [64]Kind
+	stack := stackBuf[:0]
+	for k := lead; ; k, _ = it.Next() {
+		switch k {
+		case Null, Bool, Number, String:
+		case Object, Array:
+			if maxDepth > 0 && len(stack) >= maxDepth {
+				return it.s[b:b], ErrMaxDepth
+			}
+			stack = append(stack, k)
+		case ObjectClose, ArrayClose:
+			open := Object
+			if k == ArrayClose {
+				open = Array
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return it.s[b:b], ErrUnexpectedToken
+			}
+			stack = stack[:len(stack)-1]
+		case Comma:
+			if len(stack) == 0 {
+				return it.s[b:b], ErrUnexpectedToken
+			}
+		case Colon:
+			if len(stack) == 0 || stack[len(stack)-1] != Object {
+				return it.s[b:b], ErrUnexpectedToken
+			}
+		case EOF:
+			return it.s[b:b], io.ErrUnexpectedEOF
+		default:
+			if it.reason != nil {
+				return it.s[b:b], it.reason
+			}
+			return it.s[b:b], ErrUnexpectedToken
+		}
+		if len(stack) == 0 {
+			return it.s[b:it.p], nil
+		}
+	}
+}
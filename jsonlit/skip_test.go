@@ -0,0 +1,76 @@
+package jsonlit
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIter_SkipValue(t *testing.T) {
+	deep := strings.Repeat("[", 100) + strings.Repeat("]", 100)
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		want     string
+		wantErr  error
+		rest     string
+	}{
+		{name: "scalar", input: ` 123 , 4`, want: `123`, rest: `,`},
+		{name: "string", input: `"a}]\"" x`, want: `"a}]\""`},
+		{name: "object", input: ` {"a":[1,{"b":"}"}],"c":{}} , 1`, want: `{"a":[1,{"b":"}"}],"c":{}}`, rest: `,`},
+		{name: "array", input: "[1,\n [2, [3]]]]", want: "[1,\n [2, [3]]]", rest: `]`},
+		{name: "empty", input: `[]`, want: `[]`},
+		{name: "loose_separators", input: `{"a" 1 "b" [1 2]}`, want: `{"a" 1 "b" [1 2]}`},
+		{name: "depth_ok", input: `[[{}]]`, maxDepth: 3, want: `[[{}]]`},
+		{name: "depth", input: `[[{}]]`, maxDepth: 2, wantErr: ErrMaxDepth},
+		{name: "deep", input: deep + ",1", want: deep},
+		{name: "mismatch", input: `{"a":[}`, wantErr: ErrUnexpectedToken},
+		{name: "colon_in_array", input: `[1:2]`, wantErr: ErrUnexpectedToken},
+		{name: "lead_close", input: `]`, wantErr: ErrUnexpectedToken},
+		{name: "lead_comma", input: `,1`, wantErr: ErrUnexpectedToken},
+		{name: "unterminated", input: `{"a":[1,2`, wantErr: io.ErrUnexpectedEOF},
+		{name: "eof", input: `  `, wantErr: io.ErrUnexpectedEOF},
+		{name: "invalid", input: `[1,"abc`, wantErr: ErrUnterminatedString},
+		{name: "bad_literal", input: `{"a":nul}`, wantErr: ErrInvalidLiteral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := NewIter([]byte(tt.input))
+			got, err := it.SkipValue(tt.maxDepth)
+			if err != tt.wantErr {
+				t.Fatalf("SkipValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(got) != tt.want {
+				t.Fatalf("SkipValue() = %q, want %q", got, tt.want)
+			}
+			if tt.rest != "" {
+				if _, s := it.Next(); string(s) != tt.rest {
+					t.Fatalf("Next() after SkipValue = %q, want %q", s, tt.rest)
+				}
+			}
+		})
+	}
+}
+
+func TestIter_SkipFrom(t *testing.T) {
+	it := NewIter(`{"known":1,"raw":{"x":[1,2]},"y":2}`)
+	var raw string
+	for k, s := it.Next(); k != EOF; k, s = it.Next() {
+		if k == String && s == `"raw"` {
+			it.Next() // :
+			lead, _ := it.Next()
+			v, err := it.SkipFrom(lead, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw = v
+		}
+	}
+	if raw != `{"x":[1,2]}` {
+		t.Fatalf("SkipFrom() = %q", raw)
+	}
+}
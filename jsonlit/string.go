@@ -25,29 +25,28 @@ func hexVal(c byte) (rune, bool) {
 
 const hexDigits = "0123456789abcdef"
 
+// EscapeString 把 s 转义后追加到 dst（不含两侧引号），不需要转义的连续字节按 8 字节一组扫描后整段复制。
 func EscapeString[S Bytes](dst []byte, s S) []byte {
+	return escapeString(dst, s, true)
+}
+
+// escapeString 实现 EscapeString，escapeSlash 为 false 时 / 原样输出（RFC 8785 只转义 "、\ 与控制字符）。
+func escapeString[S Bytes](dst []byte, s S, escapeSlash bool) []byte {
 	begin := 0
-	i := 0
-	for i < len(s) {
+	for i := indexEscape(s, 0); i < len(s); i = indexEscape(s, begin) {
 		c := s[i]
-		if int(c) < len(escapeTable) && escapeTable[c] != rawMark {
-			if begin < i {
-				dst = append(dst, s[begin:i]...)
-			}
+		if begin < i {
+			dst = append(dst, s[begin:i]...)
+		}
+		if c == '/' && !escapeSlash {
+			dst = append(dst, c)
+		} else if escapeTable[c] != rawMark {
 			dst = append(dst, '\\', escapeTable[c])
-			i++
-			begin = i
-		} else if c < 0x20 {
+		} else {
 			// 其它控制字符按 \uXXXX 转义，避免产出非法 JSON
-			if begin < i {
-				dst = append(dst, s[begin:i]...)
-			}
 			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
-			i++
-			begin = i
-		} else {
-			i++
 		}
+		begin = i + 1
 	}
 	if begin < len(s) {
 		dst = append(dst, s[begin:]...)
@@ -55,22 +54,31 @@ func EscapeString[S Bytes](dst []byte, s S) []byte {
 	return dst
 }
 
+// UnescapeString 把 JSON 字符串的内容 s（不含引号）反转义后追加到 dst，转义不合法时返回 false。
+// 不成对的 \uD800-\uDFFF 代理被替换为 U+FFFD。
 func UnescapeString[S Bytes](dst []byte, s S) ([]byte, bool) {
+	dst, err := unescapeString(dst, s, false)
+	return dst, err == nil
+}
+
+// unescapeString 是 UnescapeString 的实现，strict 时不成对的代理返回 ErrLoneSurrogate 而不是替换为 U+FFFD，
+// 其它不合法的转义返回 ErrInvalidEscape。
+func unescapeString[S Bytes](dst []byte, s S, strict bool) ([]byte, error) {
 	i := 0
 	for i < len(s) {
 		c := s[i]
 		if c == '\\' {
 			i++
 			if i >= len(s) {
-				return nil, false
+				return nil, ErrInvalidEscape
 			}
 			c = s[i]
 			if int(c) >= len(unescapeTable) || unescapeTable[c] == rawMark {
-				return nil, false
+				return nil, ErrInvalidEscape
 			}
 			if c == 'u' {
 				if i+4 >= len(s) {
-					return nil, false
+					return nil, ErrInvalidEscape
 				}
 				uc := rune(0)
 				for k := 0; k < 4; k++ {
@@ -78,7 +86,7 @@ func UnescapeString[S Bytes](dst []byte, s S) ([]byte, bool) {
 					c = s[i]
 					d, ok := hexVal(c)
 					if !ok {
-						return nil, false
+						return nil, ErrInvalidEscape
 					}
 					uc = uc<<4 | d
 				}
@@ -103,6 +111,9 @@ func UnescapeString[S Bytes](dst []byte, s S) ([]byte, bool) {
 						// 由 EncodeRune 编码为替换字符。
 					}
 				}
+				if strict && uc >= 0xD800 && uc <= 0xDFFF {
+					return nil, ErrLoneSurrogate
+				}
 				var u8 [6]byte
 				n := utf8.EncodeRune(u8[:], uc)
 				dst = append(dst, u8[:n]...)
@@ -114,5 +125,5 @@ func UnescapeString[S Bytes](dst []byte, s S) ([]byte, bool) {
 		}
 		i++
 	}
-	return dst, true
+	return dst, nil
 }
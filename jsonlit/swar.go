@@ -0,0 +1,62 @@
+package jsonlit
+
+import "math/bits"
+
+// 以下按 SWAR（SIMD within a register）方式一次检查 8 字节：把 8 字节装入 uint64，
+// 用整数运算同时判断每个字节，再由最低的标记位定位第一个匹配的字节。不足 8 字节的尾部逐字节检查。
+
+const (
+	swarLo = 0x0101010101010101
+	swarHi = 0x8080808080808080
+)
+
+// load64 按小端序读取 s[i:i+8]，编译器会把逐字节读取合并为一次 8 字节读取。
+func load64[S Bytes](s S, i int) uint64 {
+	_ = s[i+7]
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+// swarEq 标记 v 中等于 c 的字节（该字节最高位置 1）。借位可能误标记真正匹配之上的字节，
+// 但最低的标记位总是真正的匹配，因此只用于查找第一个匹配。
+func swarEq(v uint64, c byte) uint64 {
+	x := v ^ (swarLo * uint64(c))
+	return (x - swarLo) &^ x & swarHi
+}
+
+// swarLess 标记 v 中小于 n 的字节（n <= 0x80），与 swarEq 一样只保证最低的标记位准确。
+func swarLess(v uint64, n byte) uint64 {
+	return (v - swarLo*uint64(n)) &^ v & swarHi
+}
+
+// indexQuoteOrBackslash 返回 s[i:] 中第一个 " 或 \ 的下标，没有时返回 max(i, len(s))。
+func indexQuoteOrBackslash[S Bytes](s S, i int) int {
+	for ; i+8 <= len(s); i += 8 {
+		v := load64(s, i)
+		if m := swarEq(v, '"') | swarEq(v, '\\'); m != 0 {
+			return i + bits.TrailingZeros64(m)>>3
+		}
+	}
+	for ; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			return i
+		}
+	}
+	return i
+}
+
+// indexEscape 返回 s[i:] 中第一个需要转义的字节（控制字符、"、/ 与 \）的下标，没有时返回 len(s)。
+func indexEscape[S Bytes](s S, i int) int {
+	for ; i+8 <= len(s); i += 8 {
+		v := load64(s, i)
+		if m := swarLess(v, 0x20) | swarEq(v, '"') | swarEq(v, '/') | swarEq(v, '\\'); m != 0 {
+			return i + bits.TrailingZeros64(m)>>3
+		}
+	}
+	for ; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '/' || c == '\\' {
+			return i
+		}
+	}
+	return i
+}
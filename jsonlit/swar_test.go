@@ -0,0 +1,140 @@
+package jsonlit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// 逐字节实现，作为 SWAR 实现的参照与基准对比
+
+func indexQuoteOrBackslashBytewise(s []byte, i int) int {
+	for ; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			return i
+		}
+	}
+	return i
+}
+
+func indexEscapeBytewise(s []byte, i int) int {
+	for ; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '/' || c == '\\' {
+			return i
+		}
+	}
+	return i
+}
+
+func escapeStringBytewise(dst []byte, s []byte) []byte {
+	begin := 0
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if int(c) < len(escapeTable) && escapeTable[c] != rawMark {
+			dst = append(dst, s[begin:i]...)
+			dst = append(dst, '\\', escapeTable[c])
+			i++
+			begin = i
+		} else if c < 0x20 {
+			dst = append(dst, s[begin:i]...)
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			i++
+			begin = i
+		} else {
+			i++
+		}
+	}
+	return append(dst, s[begin:]...)
+}
+
+func TestSwarIndex(t *testing.T) {
+	// 每个位置依次放入两个任意字节，覆盖借位导致的误标记
+	buf := bytes.Repeat([]byte{'a'}, 19)
+	for pos := 0; pos+1 < len(buf); pos++ {
+		for c1 := 0; c1 < 256; c1++ {
+			for c2 := 0; c2 < 256; c2 += 3 {
+				buf[pos], buf[pos+1] = byte(c1), byte(c2)
+				for _, start := range []int{0, pos} {
+					if got, want := indexQuoteOrBackslash(buf, start), indexQuoteOrBackslashBytewise(buf, start); got != want {
+						t.Fatalf("indexQuoteOrBackslash(%x, %d) = %d, want %d", buf, start, got, want)
+					}
+					if got, want := indexEscape(buf, start), indexEscapeBytewise(buf, start); got != want {
+						t.Fatalf("indexEscape(%x, %d) = %d, want %d", buf, start, got, want)
+					}
+					if got, want := indexEscape(string(buf), start), indexEscapeBytewise(buf, start); got != want {
+						t.Fatalf("indexEscape(%q, %d) = %d, want %d", buf, start, got, want)
+					}
+				}
+			}
+		}
+		buf[pos], buf[pos+1] = 'a', 'a'
+	}
+}
+
+func TestEscapeString_bytewise(t *testing.T) {
+	var all []byte
+	for c := 0; c < 256; c++ {
+		all = append(all, byte(c), 'x', 'y')
+	}
+	inputs := [][]byte{
+		nil,
+		all,
+		[]byte(strings.Repeat("long text without escapes ", 10)),
+		[]byte("你好 \"world\"\n</script>\\"),
+	}
+	for _, s := range inputs {
+		if got, want := EscapeString(nil, s), escapeStringBytewise(nil, s); !bytes.Equal(got, want) {
+			t.Fatalf("EscapeString(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+var benchLongString = []byte(`"` + strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. 你好世界. ", 64) + `\n"`)
+
+func BenchmarkIter_longString(b *testing.B) {
+	it := NewIter(benchLongString)
+	b.SetBytes(int64(len(benchLongString)))
+	for i := 0; i < b.N; i++ {
+		it.Reset(benchLongString)
+		if k, _ := it.Next(); k != String {
+			b.Fatal(k)
+		}
+	}
+}
+
+func BenchmarkIter_longString_bytewise(b *testing.B) {
+	s := benchLongString
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		p := 1
+		for {
+			p = indexQuoteOrBackslashBytewise(s, p)
+			if s[p] != '\\' {
+				break
+			}
+			p += 2
+		}
+		if p != len(s)-1 {
+			b.Fatal(p)
+		}
+	}
+}
+
+func BenchmarkEscapeString_long(b *testing.B) {
+	s := benchLongString[1 : len(benchLongString)-3]
+	dst := make([]byte, 0, 2*len(s))
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		dst = EscapeString(dst[:0], s)
+	}
+}
+
+func BenchmarkEscapeString_long_bytewise(b *testing.B) {
+	s := benchLongString[1 : len(benchLongString)-3]
+	dst := make([]byte, 0, 2*len(s))
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		dst = escapeStringBytewise(dst[:0], s)
+	}
+}
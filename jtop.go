@@ -1,25 +1,86 @@
 package jsonpb
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"slices"
+	"sort"
 	"strconv"
+	"sync"
 
 	"github.com/vizee/jsonpb/jsonlit"
 	"github.com/vizee/jsonpb/proto"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 type JsonIter = jsonlit.Iter[[]byte]
 
+// JsonLexer 是 json->proto 转码读取 JSON token 的接口，
+// *JsonIter（内存中的 JSON）与 *jsonlit.ReaderIter（从 io.Reader 增量读取）均实现该接口。
+// Next 返回的切片只需在下一次调用 Next/EOF 之前有效。
+type JsonLexer interface {
+	Next() (jsonlit.Kind, []byte)
+	EOF() bool
+}
+
 var (
 	ErrUnexpectedToken = errors.New("unexpected token")
 	ErrTypeMismatch    = errors.New("field type mismatch")
+	ErrNotInteger      = errors.New("non-integer number for integer field")
 )
 
-func transJsonRepeatedMessage(p *proto.Encoder, j *JsonIter, field *Field) error {
-	var buf proto.Encoder
+// SyntaxError 记录 ErrUnexpectedToken 发生的位置，可用 errors.Is(err, ErrUnexpectedToken) 判断。
+// 出错的 token 本身不合法时 Reason 是词法错误的原因（如 jsonlit.ErrUnterminatedString），同样可用 errors.Is 判断。
+type SyntaxError struct {
+	Pos    jsonlit.Position
+	Reason error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Reason != nil {
+		return e.Pos.String() + ": " + e.Reason.Error()
+	}
+	return e.Pos.String() + ": " + ErrUnexpectedToken.Error()
+}
+
+func (e *SyntaxError) Unwrap() []error {
+	if e.Reason != nil {
+		return []error{ErrUnexpectedToken, e.Reason}
+	}
+	return []error{ErrUnexpectedToken}
+}
+
+// syntaxError 把 ErrUnexpectedToken 包装为标注了 j 最近一个 token 位置的 SyntaxError，其它错误原样返回。
+func syntaxError(j JsonLexer, err error) error {
+	if err != ErrUnexpectedToken {
+		return err
+	}
+	switch l := j.(type) {
+	case *JsonIter:
+		return &SyntaxError{Pos: l.Position(l.TokenOffset()), Reason: l.Reason()}
+	case *jsonlit.ReaderIter:
+		return &SyntaxError{Pos: l.Position(l.TokenOffset()), Reason: l.Reason()}
+	}
+	return err
+}
+
+// checkFull 在固定容量的 p 已超出容量、且 start 之后写出了不会再被截断的内容时返回 io.ErrShortBuffer，
+// 使 TranscodeInto 确定输出放不下后即停止，见 proto.NewFixedEncoder。只有空的子消息与 packed 字段会被截断。
+func checkFull(p *proto.Encoder, start int) error {
+	if p.Overflow() && p.Len() > start {
+		return io.ErrShortBuffer
+	}
+	return nil
+}
+
+func (st *jtopState) transJsonRepeatedMessage(p *proto.Encoder, j JsonLexer, field *Field) error {
+	n := 0
+	start := p.Len()
 	for !j.EOF() {
 		tok, _ := j.Next()
 		switch tok {
@@ -27,15 +88,26 @@ func transJsonRepeatedMessage(p *proto.Encoder, j *JsonIter, field *Field) error
 			return nil
 		case jsonlit.Comma:
 		case jsonlit.Object:
-			buf.Clear()
-			err := transJsonObject(&buf, j, field.Ref)
+			n++
+			if err := st.limits.checkRepeated(n); err != nil {
+				return err
+			}
+			if err := checkFull(p, start); err != nil {
+				return err
+			}
+			beginMessage(p, field)
+			err := st.transJsonObject(p, j, field.Ref)
+			p.EndMessage()
 			if err != nil {
 				return err
 			}
-			p.EmitBytes(field.Tag, buf.Bytes())
 		case jsonlit.Null:
+			n++
+			if err := st.limits.checkRepeated(n); err != nil {
+				return err
+			}
 			// null 会表达为一个空对象占位
-			p.EmitBytes(field.Tag, nil)
+			emitMessage(p, field, nil)
 		default:
 			return ErrUnexpectedToken
 		}
@@ -43,7 +115,9 @@ func transJsonRepeatedMessage(p *proto.Encoder, j *JsonIter, field *Field) error
 	return io.ErrUnexpectedEOF
 }
 
-func walkJsonArray(j *JsonIter, expect jsonlit.Kind, f func([]byte) error) error {
+func (st *jtopState) walkJsonArray(p *proto.Encoder, j JsonLexer, expect jsonlit.Kind, f func([]byte) error) error {
+	n := 0
+	start := p.Len()
 	for !j.EOF() {
 		tok, s := j.Next()
 		switch tok {
@@ -51,6 +125,18 @@ func walkJsonArray(j *JsonIter, expect jsonlit.Kind, f func([]byte) error) error
 			return nil
 		case jsonlit.Comma:
 		case expect:
+			n++
+			if err := st.limits.checkRepeated(n); err != nil {
+				return err
+			}
+			if err := checkFull(p, start); err != nil {
+				return err
+			}
+			if expect == jsonlit.String {
+				if err := st.limits.checkStringLen(len(s) - 2); err != nil {
+					return err
+				}
+			}
 			err := f(s)
 			if err != nil {
 				return err
@@ -62,169 +148,104 @@ func walkJsonArray(j *JsonIter, expect jsonlit.Kind, f func([]byte) error) error
 	return io.ErrUnexpectedEOF
 }
 
-func transJsonArrayField(p *proto.Encoder, j *JsonIter, field *Field) error {
+func (st *jtopState) transJsonArrayField(p *proto.Encoder, j JsonLexer, field *Field) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
 	switch field.Kind {
-	case MessageKind:
-		return transJsonRepeatedMessage(p, j, field)
+	case MessageKind, GroupKind:
+		return st.transJsonRepeatedMessage(p, j, field)
 	case BytesKind:
 		// 暂不允许 null 转到 bytes
-		err := walkJsonArray(j, jsonlit.String, func(s []byte) error {
-			return transJsonBytes(p, field.Tag, false, s)
+		err := st.walkJsonArray(p, j, jsonlit.String, func(s []byte) error {
+			return st.transJsonBytes(p, field.Tag, false, s)
 		})
 		if err != nil {
 			return err
 		}
 	case StringKind:
-		err := walkJsonArray(j, jsonlit.String, func(s []byte) error {
-			return transJsonString(p, field.Tag, false, s)
+		err := st.walkJsonArray(p, j, jsonlit.String, func(s []byte) error {
+			return st.transJsonString(p, field, false, s)
 		})
 		if err != nil {
 			return err
 		}
 	default:
-		var (
-			packed proto.Encoder
-			err    error
-		)
-		switch field.Kind {
-		case DoubleKind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseFloat(asString(s), 64)
-				if err != nil {
-					return err
-				}
-				packed.WriteFixed64(math.Float64bits(x))
-				return nil
-			})
-		case FloatKind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseFloat(asString(s), 32)
-				if err != nil {
-					return err
-				}
-				packed.WriteFixed32(math.Float32bits(float32(x)))
-				return nil
-			})
-		case Int32Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 32)
-				if err != nil {
-					return err
-				}
-				packed.WriteVarint(uint64(x))
-				return nil
-			})
-		case Int64Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 64)
-				if err != nil {
-					return err
-				}
-				packed.WriteVarint(uint64(x))
-				return nil
-			})
-		case Uint32Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseUint(asString(s), 10, 32)
-				if err != nil {
-					return err
-				}
-				packed.WriteVarint(x)
-				return nil
-			})
-		case Uint64Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseUint(asString(s), 10, 64)
-				if err != nil {
-					return err
-				}
-				packed.WriteVarint(x)
-				return nil
-			})
-		case Sint32Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 32)
-				if err != nil {
-					return err
-				}
-				packed.WriteZigzag(x)
-				return nil
-			})
-		case Sint64Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 64)
-				if err != nil {
-					return err
-				}
-				packed.WriteZigzag(x)
-				return nil
-			})
-		case Fixed32Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseUint(asString(s), 10, 32)
-				if err != nil {
-					return err
-				}
-				packed.WriteFixed32(uint32(x))
-				return nil
-			})
-		case Fixed64Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseUint(asString(s), 10, 64)
-				if err != nil {
-					return err
-				}
-				packed.WriteFixed64(x)
-				return nil
-			})
-		case Sfixed32Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 32)
-				if err != nil {
-					return err
+		lit := jsonlit.Number
+		if field.Kind == BoolKind {
+			lit = jsonlit.Bool
+		} else if !IsNumericKind(field.Kind) {
+			return ErrTypeMismatch
+		}
+		// Unpacked 时每个元素单独带 tag 写出，否则合并为一个 packed 值（与子消息一样是 length-delimited）
+		mark := p.Len()
+		if !field.Unpacked {
+			p.BeginMessage(field.Tag)
+		}
+		err := st.walkJsonArray(p, j, lit, func(s []byte) error {
+			var x uint64
+			if lit == jsonlit.Bool {
+				if len(s) == 4 {
+					x = 1
 				}
-				packed.WriteFixed32(uint32(x))
-				return nil
-			})
-		case Sfixed64Kind:
-			err = walkJsonArray(j, jsonlit.Number, func(s []byte) error {
-				x, err := strconv.ParseInt(asString(s), 10, 64)
+			} else {
+				var err error
+				x, err = parseJsonNumeric(field.Kind, s)
 				if err != nil {
 					return err
 				}
-				packed.WriteFixed64(uint64(x))
-				return nil
-			})
-		case BoolKind:
-			err = walkJsonArray(j, jsonlit.Bool, func(s []byte) error {
-				var x uint64
-				if len(s) == 4 {
-					x = 1
-				} else {
-					x = 0
-				}
-				packed.WriteVarint(x)
-				return nil
-			})
-		default:
-			err = ErrTypeMismatch
+			}
+			if field.Unpacked {
+				emitNumeric(p, field.Tag, field.Kind, x)
+			} else {
+				writeNumeric(p, field.Kind, x)
+			}
+			return nil
+		})
+		if !field.Unpacked && p.EndMessage() == 0 {
+			p.Truncate(mark)
 		}
 		if err != nil {
 			return err
 		}
-		if packed.Len() != 0 {
-			p.EmitBytes(field.Tag, packed.Bytes())
-		}
 	}
 	return nil
 }
 
-func transJsonToMap(p *proto.Encoder, j *JsonIter, tag uint32, entry *Message) error {
+func (st *jtopState) transJsonToMap(p *proto.Encoder, j JsonLexer, tag uint32, entry *Message) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
 	keyField, valueField := entry.FieldByTag(1), entry.FieldByTag(2)
 	// assert(keyField != nil && valueField != nil)
 
-	var buf proto.Encoder
+	// Deterministic 下 entry 先写入暂存缓冲，结束时按 key 排序写出；
+	// 且与 proto.Marshal 一致，entry 的 key 与 value 总是写出。
+	var (
+		sorter *segmentSorter
+		skey   string
+		nkey   uint64
+	)
+	out := p
+	if st.opts.Deterministic {
+		sorter = &segmentSorter{}
+		out = &sorter.buf
+	}
+
+	// entry 在读到 key 时开始写出，读完 value 后结束；expectValue 时 entry 尚未结束
+	n := 0
+	start := 0
+	mapStart := out.Len()
 	expectValue := false
+	defer func() {
+		if expectValue {
+			out.EndMessage()
+		}
+	}()
 	for !j.EOF() {
 		lead, s := j.Next()
 		switch lead {
@@ -232,6 +253,9 @@ func transJsonToMap(p *proto.Encoder, j *JsonIter, tag uint32, entry *Message) e
 			if expectValue {
 				return ErrUnexpectedToken
 			}
+			if sorter != nil {
+				sorter.writeTo(p)
+			}
 			return nil
 		case jsonlit.Comma, jsonlit.Colon:
 			// 忽略语法检查
@@ -239,32 +263,50 @@ func transJsonToMap(p *proto.Encoder, j *JsonIter, tag uint32, entry *Message) e
 		default:
 			if expectValue {
 				// NOTE: transJsonField 会跳过 0 值字段，导致结果比 proto.Marshal 的结果字节数更少，但不影响反序列化结果
-				err := transJsonField(&buf, j, valueField, lead, s)
+				err := st.transJsonValue(out, j, valueField, lead, s, sorter == nil)
+				out.EndMessage()
+				expectValue = false
 				if err != nil {
 					return err
 				}
-				if buf.Len() != 0 {
-					p.EmitBytes(tag, buf.Bytes())
+				if sorter != nil {
+					sorter.add(nkey, skey, start)
 				}
-				expectValue = false
 			} else if lead == jsonlit.String {
-				buf.Clear()
+				n++
+				if err := st.limits.checkMapEntries(n); err != nil {
+					return err
+				}
+				if sorter == nil {
+					if err := checkFull(out, mapStart); err != nil {
+						return err
+					}
+				}
+				if err := st.limits.checkStringLen(len(s) - 2); err != nil {
+					return err
+				}
+				if keyField.Kind != StringKind && !IsNumericKind(keyField.Kind) {
+					return ErrTypeMismatch
+				}
+				start = out.Len()
+				out.BeginMessage(tag)
+				expectValue = true
 				if keyField.Kind == StringKind {
 					// map 的 key 必须始终写出（即使为空串），否则默认 key+默认 value 的 entry 会被丢弃
-					err := transJsonString(&buf, 1, false, s)
+					err := st.transJsonString(out, keyField, false, s)
 					if err != nil {
 						return err
 					}
-				} else if IsNumericKind(keyField.Kind) {
+				} else {
 					// 允许把 json key 转为将数值类型的 map key；omitEmpty=false 保证 0 键不被丢弃
-					err := transJsonNumeric(&buf, 1, keyField.Kind, s[1:len(s)-1], false)
+					err := transJsonNumeric(out, 1, keyField.Kind, s[1:len(s)-1], false)
 					if err != nil {
 						return err
 					}
-				} else {
-					return ErrTypeMismatch
 				}
-				expectValue = true
+				if sorter != nil {
+					skey, nkey = mapSortKey(keyField.Kind, s)
+				}
 			} else {
 				return ErrUnexpectedToken
 			}
@@ -273,6 +315,135 @@ func transJsonToMap(p *proto.Encoder, j *JsonIter, tag uint32, entry *Message) e
 	return io.ErrUnexpectedEOF
 }
 
+// segment 是暂存缓冲中一段已编码的字段或 map entry。
+type segment struct {
+	start, end int
+	num        uint64
+	str        string
+}
+
+// segmentSorter 用于 Deterministic 模式：先把字段/map entry 编码到 buf，再按 (num, str) 升序写出。
+type segmentSorter struct {
+	buf  proto.Encoder
+	segs []segment
+}
+
+// add 记录从 start 到 buf 末尾的一段编码，num/str 为排序 key。
+func (s *segmentSorter) add(num uint64, str string, start int) {
+	s.segs = append(s.segs, segment{start: start, end: s.buf.Len(), num: num, str: str})
+}
+
+// writeTo 按 key 升序把各段写到 p，相同 key 只保留最后一段（JSON 重复 key 时 last-one-wins）。
+func (s *segmentSorter) writeTo(p *proto.Encoder) {
+	segs := s.segs
+	sort.SliceStable(segs, func(a, b int) bool {
+		if segs[a].num != segs[b].num {
+			return segs[a].num < segs[b].num
+		}
+		return segs[a].str < segs[b].str
+	})
+	buf := s.buf.Bytes()
+	for i := range segs {
+		if i+1 < len(segs) && segs[i+1].num == segs[i].num && segs[i+1].str == segs[i].str {
+			continue
+		}
+		p.WriteBytes(buf[segs[i].start:segs[i].end])
+	}
+}
+
+// fieldSpan 是 sortFields 中一个字段的编码 b[start:end]，ref 非 nil 时 b[body:bodyEnd] 是需要递归处理的子消息/group 内容。
+type fieldSpan struct {
+	start, end    int
+	body, bodyEnd int
+	tag           uint32
+	ref           *Message
+}
+
+// sortOutput 在本次转码出现过不按 tag 升序的 key 时，把 p 中从 start 开始的 msg 的编码按 tag 重排。
+// 与规范编码器一样按 tag 升序写出，TranscodeReaderToJson 才能边读边转译这些输出。
+// 调用时 p 中不能有未结束的子消息；只计算长度时重排不影响结果，不做处理。
+func (st *jtopState) sortOutput(p *proto.Encoder, start int, msg *Message) {
+	if !st.unsorted {
+		return
+	}
+	st.unsorted = false
+	if p.Sizing() || p.Overflow() {
+		return
+	}
+	st.sortFields(p.Bytes()[start:], msg)
+}
+
+// sortFields 把 b 中 msg 的字段按 tag 稳定排序（同一字段的出现保持原有顺序，last-one-wins 不变），
+// 并递归处理子消息、map entry 与 group 的内容。重排不改变各段的长度，b 原地改写。
+func (st *jtopState) sortFields(b []byte, msg *Message) {
+	sc := st.acquireScratch()
+	base := len(sc.spans)
+	defer func() {
+		sc.spans = sc.spans[:base]
+	}()
+	sorted := true
+	for off := 0; off < len(b); {
+		num, wire, n := protowire.ConsumeTag(b[off:])
+		if n < 0 {
+			return
+		}
+		m := protowire.ConsumeFieldValue(num, wire, b[off+n:])
+		if m < 0 {
+			return
+		}
+		span := fieldSpan{start: off, end: off + n + m, tag: uint32(num)}
+		if field := msg.FieldByTag(span.tag); field != nil && field.Ref != nil {
+			switch {
+			case wire == protowire.BytesType && (field.Kind == MessageKind || field.Kind == MapKind):
+				_, k := protowire.ConsumeVarint(b[off+n:])
+				span.body, span.bodyEnd, span.ref = off+n+k, span.end, field.Ref
+			case wire == protowire.StartGroupType && field.Kind == GroupKind:
+				span.body, span.bodyEnd, span.ref = off+n, span.end-protowire.SizeTag(num), field.Ref
+			}
+		}
+		if top := len(sc.spans); top > base && span.tag < sc.spans[top-1].tag {
+			sorted = false
+		}
+		sc.spans = append(sc.spans, span)
+		off = span.end
+	}
+	top := len(sc.spans)
+	for i := base; i < top; i++ {
+		if span := sc.spans[i]; span.ref != nil {
+			st.sortFields(b[span.body:span.bodyEnd], span.ref)
+		}
+	}
+	if sorted {
+		return
+	}
+	spans := sc.spans[base:top]
+	slices.SortStableFunc(spans, func(x, y fieldSpan) int {
+		return cmp.Compare(x.tag, y.tag)
+	})
+	sc.buf = sc.buf[:0]
+	for _, span := range spans {
+		sc.buf = append(sc.buf, b[span.start:span.end]...)
+	}
+	copy(b, sc.buf)
+}
+
+// mapSortKey 返回 map key（JSON 字符串 token）的排序 key，顺序与 proto.MarshalOptions{Deterministic: true} 一致：
+// 字符串按字节序，有符号整数按数值（翻转符号位后按无符号比较），无符号整数按数值。
+// 调用前 key 已经过校验。
+func mapSortKey(kind Kind, s []byte) (string, uint64) {
+	switch kind {
+	case StringKind:
+		key, _ := jsonlit.UnescapeString(nil, s[1:len(s)-1])
+		return string(key), 0
+	case Uint32Kind, Uint64Kind, Fixed32Kind, Fixed64Kind:
+		x, _ := strconv.ParseUint(asString(s[1:len(s)-1]), 10, 64)
+		return "", x
+	default:
+		x, _ := strconv.ParseInt(asString(s[1:len(s)-1]), 10, 64)
+		return "", uint64(x) ^ (1 << 63)
+	}
+}
+
 // isNumericZero 判断 JSON 数值字面量是否表示零。
 // 浮点类型接受 0、0.0、0e0、-0 等形式；整数类型仅接受 "0"，
 // 以便对 "0.0" 这类非法整数字面量仍能走后续解析报错。
@@ -292,111 +463,161 @@ func isNumericZero(s []byte, kind Kind) bool {
 	return len(s) == 1 && s[0] == '0'
 }
 
-func transJsonNumeric(p *proto.Encoder, tag uint32, kind Kind, s []byte, omitEmpty bool) error {
-	if !IsNumericKind(kind) {
-		return ErrTypeMismatch
-	}
-	// 提前检查 0 值：仅当 omitEmpty 时跳过（proto3 默认值不序列化）。
-	// map 的 key 必须始终写出，因此传 omitEmpty=false。
-	if omitEmpty && isNumericZero(s, kind) {
-		return nil
-	}
+// parseJsonNumeric 把 JSON 数值解析为 kind 对应的 wire 值：浮点为 IEEE 754 位模式，
+// sint 为 zigzag 编码，32 位有符号整数按 64 位符号扩展。
+func parseJsonNumeric(kind Kind, s []byte) (uint64, error) {
 	switch kind {
 	case DoubleKind:
 		x, err := strconv.ParseFloat(asString(s), 64)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		p.EmitFixed64(tag, math.Float64bits(x))
+		return math.Float64bits(x), nil
 	case FloatKind:
 		x, err := strconv.ParseFloat(asString(s), 32)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		p.EmitFixed32(tag, math.Float32bits(float32(x)))
-	case Int32Kind:
+		return uint64(math.Float32bits(float32(x))), nil
+	case Int32Kind, Sfixed32Kind:
 		x, err := strconv.ParseInt(asString(s), 10, 32)
 		if err != nil {
-			return err
+			return 0, intError(s, err)
+		}
+		if kind == Sfixed32Kind {
+			return uint64(uint32(x)), nil
 		}
-		p.EmitVarint(tag, uint64(x))
-	case Int64Kind:
+		return uint64(x), nil
+	case Int64Kind, Sfixed64Kind:
 		x, err := strconv.ParseInt(asString(s), 10, 64)
 		if err != nil {
-			return err
+			return 0, intError(s, err)
 		}
-		p.EmitVarint(tag, uint64(x))
-	case Uint32Kind:
+		return uint64(x), nil
+	case Uint32Kind, Fixed32Kind:
 		x, err := strconv.ParseUint(asString(s), 10, 32)
 		if err != nil {
-			return err
+			return 0, intError(s, err)
 		}
-		p.EmitVarint(tag, uint64(x))
-	case Uint64Kind:
+		return x, nil
+	case Uint64Kind, Fixed64Kind:
 		x, err := strconv.ParseUint(asString(s), 10, 64)
 		if err != nil {
-			return err
+			return 0, intError(s, err)
 		}
-		p.EmitVarint(tag, x)
+		return x, nil
 	case Sint32Kind:
 		x, err := strconv.ParseInt(asString(s), 10, 32)
 		if err != nil {
-			return err
+			return 0, intError(s, err)
 		}
-		p.EmitZigzag(tag, x)
+		return protowire.EncodeZigZag(x), nil
 	case Sint64Kind:
 		x, err := strconv.ParseInt(asString(s), 10, 64)
 		if err != nil {
-			return err
-		}
-		p.EmitZigzag(tag, x)
-	case Fixed32Kind:
-		x, err := strconv.ParseUint(asString(s), 10, 32)
-		if err != nil {
-			return err
+			return 0, intError(s, err)
 		}
+		return protowire.EncodeZigZag(x), nil
+	}
+	return 0, ErrTypeMismatch
+}
+
+// intError 在整数解析失败时区分出浮点字面量（如 1.0、1e2），整数字段不接受这类写法。
+func intError(s []byte, err error) error {
+	if jsonlit.ClassifyNumber(s) == jsonlit.FloatNumber {
+		return fmt.Errorf("%w: %s", ErrNotInteger, s)
+	}
+	return err
+}
+
+// writeNumeric 按 kind 的 wire 类型写出 parseJsonNumeric 的结果（不带 tag，用于 packed）。
+func writeNumeric(p *proto.Encoder, kind Kind, x uint64) {
+	switch wireTypeOfKind[kind] {
+	case protowire.VarintType:
+		p.WriteVarint(x)
+	case protowire.Fixed32Type:
+		p.WriteFixed32(uint32(x))
+	case protowire.Fixed64Type:
+		p.WriteFixed64(x)
+	}
+}
+
+// emitNumeric 按 kind 的 wire 类型写出 parseJsonNumeric 的结果。
+func emitNumeric(p *proto.Encoder, tag uint32, kind Kind, x uint64) {
+	switch wireTypeOfKind[kind] {
+	case protowire.VarintType:
+		p.EmitVarint(tag, x)
+	case protowire.Fixed32Type:
 		p.EmitFixed32(tag, uint32(x))
-	case Fixed64Kind:
-		x, err := strconv.ParseUint(asString(s), 10, 64)
-		if err != nil {
-			return err
-		}
+	case protowire.Fixed64Type:
 		p.EmitFixed64(tag, x)
-	case Sfixed32Kind:
-		x, err := strconv.ParseInt(asString(s), 10, 32)
-		if err != nil {
-			return err
-		}
-		p.EmitFixed32(tag, uint32(x))
-	case Sfixed64Kind:
-		x, err := strconv.ParseInt(asString(s), 10, 64)
-		if err != nil {
-			return err
-		}
-		p.EmitFixed64(tag, uint64(x))
 	}
+}
+
+func transJsonNumeric(p *proto.Encoder, tag uint32, kind Kind, s []byte, omitEmpty bool) error {
+	if !IsNumericKind(kind) {
+		return ErrTypeMismatch
+	}
+	// 提前检查 0 值：仅当 omitEmpty 时跳过（proto3 默认值不序列化）。
+	// map 的 key 必须始终写出，因此传 omitEmpty=false。
+	if omitEmpty && isNumericZero(s, kind) {
+		return nil
+	}
+	x, err := parseJsonNumeric(kind, s)
+	if err != nil {
+		return err
+	}
+	emitNumeric(p, tag, kind, x)
 	return nil
 }
 
-func transJsonString(p *proto.Encoder, tag uint32, omitEmpty bool, s []byte) error {
+func (st *jtopState) transJsonString(p *proto.Encoder, field *Field, omitEmpty bool, s []byte) error {
 	if len(s) == 2 && omitEmpty {
 		return nil
 	}
-	z := make([]byte, 0, len(s)-2)
-	z, ok := jsonlit.UnescapeString(z, s[1:len(s)-1])
-	if !ok {
-		return errors.New("unescape malformed string")
+	z := s[1 : len(s)-1]
+	// 不含转义的字符串直接写出，否则反转义到 scratch 缓冲
+	if bytes.IndexByte(z, '\\') >= 0 {
+		sc := st.acquireScratch()
+		var ok bool
+		sc.buf, ok = jsonlit.UnescapeString(sc.buf[:0], z)
+		if !ok {
+			return errors.New("unescape malformed string")
+		}
+		z = sc.buf
+	}
+	z, err := validUTF8(z, fieldUTF8Mode(field, st.opts.UTF8))
+	if err != nil {
+		return err
 	}
-	p.EmitBytes(tag, z)
+	p.EmitBytes(field.Tag, z)
 	return nil
 }
 
-func transJsonBytes(p *proto.Encoder, tag uint32, omitEmpty bool, s []byte) error {
+func (st *jtopState) transJsonBytes(p *proto.Encoder, tag uint32, omitEmpty bool, s []byte) error {
 	if len(s) == 2 && omitEmpty {
 		return nil
 	}
-	z := make([]byte, base64.StdEncoding.DecodedLen(len(s)-2))
-	n, err := base64.StdEncoding.Decode(z, s[1:len(s)-1])
+	// 与 protobuf JSON 规范一致，接受标准与 URL-safe 字母表，padding 可有可无
+	src := s[1 : len(s)-1]
+	urlSafe := bytes.ContainsAny(src, "-_")
+	var enc *base64.Encoding
+	switch {
+	case urlSafe && len(src)%4 != 0:
+		enc = base64.RawURLEncoding
+	case urlSafe:
+		enc = base64.URLEncoding
+	case len(src)%4 != 0:
+		enc = base64.RawStdEncoding
+	default:
+		enc = base64.StdEncoding
+	}
+	sc := st.acquireScratch()
+	if n := enc.DecodedLen(len(src)); cap(sc.buf) < n {
+		sc.buf = make([]byte, n)
+	}
+	z := sc.buf[:cap(sc.buf)]
+	n, err := enc.Decode(z, src)
 	if err != nil {
 		return err
 	}
@@ -404,23 +625,46 @@ func transJsonBytes(p *proto.Encoder, tag uint32, omitEmpty bool, s []byte) erro
 	return nil
 }
 
-func transJsonField(p *proto.Encoder, j *JsonIter, field *Field, lead jsonlit.Kind, s []byte) error {
+func (st *jtopState) transJsonField(p *proto.Encoder, j JsonLexer, field *Field, lead jsonlit.Kind, s []byte) error {
+	// 有 presence 的 proto2 字段零值也要写出，null 仍表示未设置
+	omitEmpty := lead == jsonlit.Null || (!field.Required && field.Default == "")
+	return st.transJsonValue(p, j, field, lead, s, omitEmpty)
+}
+
+// transJsonValue 转译一个字段值，omitEmpty 为 false 时零值与 null 也会写出（用于 Deterministic 下的 map value）。
+func (st *jtopState) transJsonValue(p *proto.Encoder, j JsonLexer, field *Field, lead jsonlit.Kind, s []byte, omitEmpty bool) error {
 	switch lead {
 	case jsonlit.String:
+		if err := st.limits.checkStringLen(len(s) - 2); err != nil {
+			return err
+		}
 		switch field.Kind {
 		case BytesKind:
-			return transJsonBytes(p, field.Tag, true, s)
+			return st.transJsonBytes(p, field.Tag, omitEmpty, s)
 		case StringKind:
-			return transJsonString(p, field.Tag, true, s)
+			return st.transJsonString(p, field, omitEmpty, s)
 		default:
 			return ErrTypeMismatch
 		}
 	case jsonlit.Number:
-		return transJsonNumeric(p, field.Tag, field.Kind, s, true)
+		if st.opts.Deterministic && omitEmpty && IsNumericKind(field.Kind) {
+			// 按解析后的值判断零值，与 proto.Marshal 一致（如 -0.0 会写出）
+			x, err := parseJsonNumeric(field.Kind, s)
+			if err != nil {
+				return err
+			}
+			if x != 0 {
+				emitNumeric(p, field.Tag, field.Kind, x)
+			}
+			return nil
+		}
+		return transJsonNumeric(p, field.Tag, field.Kind, s, omitEmpty)
 	case jsonlit.Bool:
 		if field.Kind == BoolKind {
 			if len(s) == 4 {
 				p.EmitVarint(field.Tag, 1)
+			} else if !omitEmpty {
+				p.EmitVarint(field.Tag, 0)
 			}
 			return nil
 		} else {
@@ -428,38 +672,79 @@ func transJsonField(p *proto.Encoder, j *JsonIter, field *Field, lead jsonlit.Ki
 		}
 	case jsonlit.Null:
 		// 忽略所有 null
+		if !omitEmpty {
+			emitZeroValue(p, field)
+		}
 		return nil
 	case jsonlit.Object:
 		switch field.Kind {
-		case MessageKind:
-			var buf proto.Encoder
-			err := transJsonObject(&buf, j, field.Ref)
+		case MessageKind, GroupKind:
+			mark := p.Len()
+			beginMessage(p, field)
+			err := st.transJsonObject(p, j, field.Ref)
+			// 出错时也结束子消息，保持 p 中子消息的嵌套平衡
+			n := p.EndMessage()
 			if err != nil {
 				return err
 			}
-			if buf.Len() != 0 {
-				p.EmitBytes(field.Tag, buf.Bytes())
+			// Deterministic 下与 proto.Marshal 一致，出现的空消息也写出
+			if n == 0 && omitEmpty && !st.opts.Deterministic {
+				p.Truncate(mark)
 			}
 			return nil
 		case MapKind:
-			return transJsonToMap(p, j, field.Tag, field.Ref)
+			return st.transJsonToMap(p, j, field.Tag, field.Ref)
 		default:
 			return ErrTypeMismatch
 		}
 	case jsonlit.Array:
 		if field.Repeated {
-			return transJsonArrayField(p, j, field)
+			return st.transJsonArrayField(p, j, field)
 		}
 		return ErrTypeMismatch
 	}
 	return ErrUnexpectedToken
 }
 
-func skipJsonValue(j *JsonIter, lead jsonlit.Kind) error {
+// emitZeroValue 写出 field 类型的零值。
+func emitZeroValue(p *proto.Encoder, field *Field) {
+	if int(field.Kind) < len(wireTypeOfKind) {
+		emitNumeric(p, field.Tag, field.Kind, 0)
+	} else {
+		emitMessage(p, field, nil)
+	}
+}
+
+// beginMessage 在 p 中开始写出 field 对应的子消息，GroupKind 写为 group，其它写为 length-delimited。
+func beginMessage(p *proto.Encoder, field *Field) {
+	if field.Kind == GroupKind {
+		p.BeginGroup(field.Tag)
+	} else {
+		p.BeginMessage(field.Tag)
+	}
+}
+
+// emitMessage 写出已编码的子消息 s，GroupKind 写为 group，其它写为 length-delimited。
+func emitMessage(p *proto.Encoder, field *Field, s []byte) {
+	if field.Kind == GroupKind {
+		p.EmitGroup(field.Tag, s)
+	} else {
+		p.EmitBytes(field.Tag, s)
+	}
+}
+
+func (st *jtopState) skipJsonValue(j JsonLexer, lead jsonlit.Kind) error {
+	if it, ok := j.(*JsonIter); ok {
+		return st.skipJsonIterValue(it, lead)
+	}
 	switch lead {
 	case jsonlit.Null, jsonlit.Bool, jsonlit.Number, jsonlit.String:
 		return nil
 	case jsonlit.Object:
+		if err := st.enter(); err != nil {
+			return err
+		}
+		defer st.leave()
 		for !j.EOF() {
 			tok, _ := j.Next()
 			switch tok {
@@ -467,13 +752,18 @@ func skipJsonValue(j *JsonIter, lead jsonlit.Kind) error {
 				return nil
 			case jsonlit.Comma, jsonlit.Colon:
 			default:
-				err := skipJsonValue(j, tok)
+				err := st.skipJsonValue(j, tok)
 				if err != nil {
 					return err
 				}
 			}
 		}
+		return io.ErrUnexpectedEOF
 	case jsonlit.Array:
+		if err := st.enter(); err != nil {
+			return err
+		}
+		defer st.leave()
 		for !j.EOF() {
 			tok, _ := j.Next()
 			switch tok {
@@ -481,7 +771,7 @@ func skipJsonValue(j *JsonIter, lead jsonlit.Kind) error {
 				return nil
 			case jsonlit.Comma:
 			default:
-				err := skipJsonValue(j, tok)
+				err := st.skipJsonValue(j, tok)
 				if err != nil {
 					return err
 				}
@@ -492,13 +782,70 @@ func skipJsonValue(j *JsonIter, lead jsonlit.Kind) error {
 	return ErrUnexpectedToken
 }
 
-func transJsonObject(p *proto.Encoder, j *JsonIter, msg *Message) error {
-	var key []byte
+// skipJsonIterValue 用 SkipFrom 非递归地跳过内存中的值，嵌套深度与递归跳过时一样受 MaxDepth 限制。
+func (st *jtopState) skipJsonIterValue(it *JsonIter, lead jsonlit.Kind) error {
+	maxDepth := 0
+	if st.limits.MaxDepth > 0 {
+		maxDepth = st.limits.MaxDepth - st.depth
+		if maxDepth <= 0 && (lead == jsonlit.Object || lead == jsonlit.Array) {
+			return &LimitError{Limit: "depth", Max: st.limits.MaxDepth}
+		}
+	}
+	_, err := it.SkipFrom(lead, maxDepth)
+	switch err {
+	case nil, io.ErrUnexpectedEOF:
+		return err
+	case jsonlit.ErrMaxDepth:
+		return &LimitError{Limit: "depth", Max: st.limits.MaxDepth}
+	}
+	return ErrUnexpectedToken
+}
+
+func (st *jtopState) transJsonObject(p *proto.Encoder, j JsonLexer, msg *Message) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
+	// Deterministic 下字段先写入暂存缓冲，对象结束时按 tag 升序写出
+	var sorter *segmentSorter
+	out := p
+	if st.opts.Deterministic {
+		sorter = &segmentSorter{}
+		out = &sorter.buf
+	}
+
+	// 已设置的 required 字段，对象结束时检查
+	var (
+		requiredBuf [8]*Field
+		required    = requiredBuf[:0]
+	)
+	objStart := p.Len()
+
+	// 读到 key 时立即查找字段，JsonLexer 返回的 token 在下一次 Next 后可能失效
+	var field *Field
+	expectValue := false
+	// lastTag 是已转译的最大 tag，之后出现更小的 tag 时输出需要重排，见 sortOutput
+	var lastTag uint32
 	for !j.EOF() {
 		lead, s := j.Next()
 		switch lead {
 		case jsonlit.ObjectClose:
-			if len(key) == 0 {
+			if !expectValue {
+				err := checkRequired(msg, func(i int) bool {
+					for _, f := range required {
+						if f == &msg.Fields[i] {
+							return true
+						}
+					}
+					return false
+				})
+				if err != nil {
+					return err
+				}
+				if sorter != nil {
+					sorter.writeTo(p)
+				}
 				return nil
 			}
 			return ErrUnexpectedToken
@@ -506,23 +853,42 @@ func transJsonObject(p *proto.Encoder, j *JsonIter, msg *Message) error {
 			// 忽略语法检查
 			continue
 		default:
-			if len(key) != 0 {
-				// 暂不转义 key
-				field := msg.FieldByName(asString(key[1 : len(key)-1]))
+			if expectValue {
 				if field != nil && field.Omit != OmitAlways {
-					err := transJsonField(p, j, field, lead, s)
+					if field.Tag < lastTag && sorter == nil {
+						st.unsorted = true
+					}
+					lastTag = max(lastTag, field.Tag)
+					start := out.Len()
+					err := st.transJsonField(out, j, field, lead, s)
 					if err != nil {
-						return err
+						return withFieldPath(err, field.Name)
+					}
+					if sorter != nil {
+						sorter.add(uint64(field.Tag), "", start)
+					}
+					if field.Required && lead != jsonlit.Null {
+						required = append(required, field)
 					}
 				} else {
-					err := skipJsonValue(j, lead)
+					err := st.skipJsonValue(j, lead)
 					if err != nil {
 						return err
 					}
 				}
-				key = nil
-			} else if lead == jsonlit.String {
-				key = s
+				expectValue = false
+			} else if lead == jsonlit.String && len(s) >= 2 {
+				if err := st.checkInputSize(); err != nil {
+					return err
+				}
+				if sorter == nil {
+					if err := checkFull(p, objStart); err != nil {
+						return err
+					}
+				}
+				// 暂不转义 key
+				field = msg.FieldByName(asString(s[1 : len(s)-1]))
+				expectValue = true
 			} else {
 				return ErrUnexpectedToken
 			}
@@ -531,15 +897,229 @@ func transJsonObject(p *proto.Encoder, j *JsonIter, msg *Message) error {
 	return io.ErrUnexpectedEOF
 }
 
-// TranscodeToProto 通过 JsonIter 解析 JSON，并且根据 msg 将 JSON 内容转译到 protobuf 二进制。
-// 注意，受限于 metadata 可表达的结构和一些取舍，对 JSON 的解析并不按照 JSON 标准。
-func TranscodeToProto(p *proto.Encoder, j *JsonIter, msg *Message) error {
+// ToProtoOptions 控制 json->proto 的转码行为，零值表示默认行为。
+type ToProtoOptions struct {
+	// Limits 为 nil 时使用 DefaultLimits
+	Limits *Limits
+	// Deterministic 输出规范化的 pb：字段按 tag 升序（默认也是如此）、map entry 按 key 排序、repeated 数值 packed，
+	// 与 proto.MarshalOptions{Deterministic: true} 对同一消息的输出逐字节一致，输出不再依赖 JSON 的 key 顺序。
+	// 为此与 proto.Marshal 一样写出出现的空消息与 map entry 的零值 value，JSON 中重复的 key 以最后一个为准。
+	Deterministic bool
+	// UTF8 控制设置了 ValidateUTF8 的 string 字段（反转义后）含非法 UTF-8 时的处理，默认返回 ErrInvalidUTF8
+	UTF8 UTF8Mode
+}
+
+var defaultToProtoOptions ToProtoOptions
+
+// jtopState 保存一次 json->proto 转码过程中的选项与嵌套状态。
+type jtopState struct {
+	opts   *ToProtoOptions
+	limits *Limits
+	depth  int
+	// lexer 是本次转码的输入，用于给语法错误标注位置与检查输入大小
+	lexer JsonLexer
+	// reader 非 nil 时输入来自 io.Reader，其窗口受 Limits 限制
+	reader *jsonlit.ReaderIter
+	// records 为 true 时 lexer 中含多条记录（NDJSON），输入大小按 base 之后已读取的字节数计算
+	records bool
+	base    int64
+	// scratch 在首次需要时从池中取得，release 时归还
+	scratch *jtopScratch
+	// unsorted 表示本次转码出现过不按 tag 升序的 key，输出需要由 sortOutput 重排
+	unsorted bool
+}
+
+// jtopScratch 是转码过程中复用的临时缓冲（子消息、packed 值与 map entry 直接编码到输出中，不需要暂存）：
+// buf 用于字符串反转义、base64 解码与 sortFields 重排字段，spans 记录 sortFields 各层的字段。
+// scratch 经 jtopScratchPool 跨次转码复用，稳定状态下转码不再分配内存。
+type jtopScratch struct {
+	buf   []byte
+	spans []fieldSpan
+}
+
+// maxPooledScratch 是归还到池中的单个缓冲的容量上限，避免偶发的大消息长期占用内存。
+const maxPooledScratch = 64 << 10
+
+var jtopScratchPool = sync.Pool{
+	New: func() any {
+		return new(jtopScratch)
+	},
+}
+
+func (st *jtopState) acquireScratch() *jtopScratch {
+	if st.scratch == nil {
+		st.scratch = jtopScratchPool.Get().(*jtopScratch)
+	}
+	return st.scratch
+}
+
+// release 把 scratch 归还到池中，之后不能再使用此前取得的临时缓冲。
+func (st *jtopState) release() {
+	sc := st.scratch
+	if sc == nil {
+		return
+	}
+	st.scratch = nil
+	if cap(sc.buf) > maxPooledScratch {
+		sc.buf = nil
+	}
+	sc.spans = sc.spans[:0]
+	jtopScratchPool.Put(sc)
+}
+
+func newJtopState(opts *ToProtoOptions) jtopState {
+	limits := opts.Limits
+	if limits == nil {
+		limits = &DefaultLimits
+	}
+	return jtopState{
+		opts:   opts,
+		limits: limits,
+	}
+}
+
+func (st *jtopState) enter() error {
+	st.depth++
+	if err := st.checkInputSize(); err != nil {
+		return err
+	}
+	return st.limits.checkDepth(st.depth)
+}
+
+// lexerOffset 返回 j 已读取的字节数，j 不支持时返回 -1。
+func lexerOffset(j JsonLexer) int64 {
+	switch l := j.(type) {
+	case *JsonIter:
+		return int64(l.Offset())
+	case *jsonlit.ReaderIter:
+		return l.Offset()
+	}
+	return -1
+}
+
+// inputSize 返回本次转码目前已知的输入字节数：内存中的输入为总长度，从 io.Reader 读取或逐条转译记录时为已读取的字节数，
+// lexer 不支持时返回 -1。
+func (st *jtopState) inputSize() int64 {
+	if l, ok := st.lexer.(*JsonIter); ok && !st.records {
+		return int64(l.Len())
+	}
+	off := lexerOffset(st.lexer)
+	if off < 0 {
+		return -1
+	}
+	return off - st.base
+}
+
+// checkInputSize 按 inputSize 检查 MaxInputSize，在转码开始、进入每层嵌套、读到每个 key 与转码结束时调用。
+func (st *jtopState) checkInputSize() error {
+	if st.limits.MaxInputSize > 0 && st.inputSize() > int64(st.limits.MaxInputSize) {
+		return &LimitError{Limit: "input size", Max: st.limits.MaxInputSize}
+	}
+	return nil
+}
+
+func (st *jtopState) leave() {
+	st.depth--
+}
+
+// Transcode 按 o 指定的选项把 JSON 转译到 protobuf 二进制，见 TranscodeToProto。
+func (o *ToProtoOptions) Transcode(p *proto.Encoder, j JsonLexer, msg *Message) error {
+	st := newJtopState(o)
+	if err := st.begin(j); err != nil {
+		return err
+	}
+	return st.end(st.transcode(p, j, msg))
+}
+
+// TranscodeInto 按 o 指定的选项把 JSON 转译到 protobuf 二进制并写入 dst，返回写出的字节数，
+// dst 的长度不足以容纳输出时返回 io.ErrShortBuffer。dst 通常按 Size 的结果分配。
+// 输出只写入 dst，不会另外分配缓冲；确定超出 dst 后即停止转码（Deterministic 下在对象结束写出时才能确定）。
+func (o *ToProtoOptions) TranscodeInto(dst []byte, j JsonLexer, msg *Message) (int, error) {
+	p := fixedEncoderPool.Get().(*proto.Encoder)
+	p.Reset(dst[:0:len(dst)])
+	err := o.Transcode(p, j, msg)
+	n, overflow := p.Len(), p.Overflow()
+	p.Reset(nil)
+	fixedEncoderPool.Put(p)
+	if err != nil {
+		return 0, err
+	}
+	if overflow {
+		return 0, io.ErrShortBuffer
+	}
+	return n, nil
+}
+
+// fixedEncoderPool 复用 TranscodeInto 的固定容量 Encoder，保留其嵌套子消息的栈
+var fixedEncoderPool = sync.Pool{
+	New: func() any {
+		return proto.NewFixedEncoder(nil)
+	},
+}
+
+// begin 在转码开始前记录输入并检查输入大小，从 io.Reader 读取时按 Limits 限制窗口，使超长的 token 不会被整体读入内存。
+func (st *jtopState) begin(j JsonLexer) error {
+	st.lexer = j
+	if l, ok := j.(*jsonlit.ReaderIter); ok {
+		st.reader = l
+		n, _ := st.limits.tokenLimit()
+		l.SetMaxWindow(n)
+	}
+	return st.checkInputSize()
+}
+
+// beginRecord 在 j 中的下一条记录开始前调用，与 begin 相同，但 MaxInputSize 只计算本条记录的字节数。
+func (st *jtopState) beginRecord(j JsonLexer) error {
+	st.records = true
+	st.base = lexerOffset(j)
+	return st.begin(j)
+}
+
+// end 在转码结束后归还 scratch 并整理错误：读取错误优先于由其导致的语法错误，语法错误标注出错 token 的位置。
+// token 超出窗口时报告对应的 LimitError。
+func (st *jtopState) end(err error) error {
+	st.release()
+	if st.reader != nil {
+		if rerr := st.reader.Err(); rerr != nil {
+			if rerr == jsonlit.ErrTokenTooLarge {
+				_, rerr = st.limits.tokenLimit()
+			}
+			return rerr
+		}
+	}
+	if err == nil {
+		err = st.checkInputSize()
+	}
+	return syntaxError(st.lexer, err)
+}
+
+func (st *jtopState) transcode(p *proto.Encoder, j JsonLexer, msg *Message) error {
 	tok, _ := j.Next()
 	switch tok {
 	case jsonlit.Object:
-		return transJsonObject(p, j, msg)
+		start := p.Len()
+		if err := st.transJsonObject(p, j, msg); err != nil {
+			return err
+		}
+		st.sortOutput(p, start, msg)
+		return nil
 	case jsonlit.EOF:
 		return io.ErrUnexpectedEOF
 	}
 	return ErrUnexpectedToken
 }
+
+// TranscodeToProto 通过 JsonLexer 解析 JSON，并且根据 msg 将 JSON 内容转译到 protobuf 二进制。
+// j 可以是内存中的 *JsonIter，也可以是从 io.Reader 增量读取的 *jsonlit.ReaderIter。
+// 注意，受限于 metadata 可表达的结构和一些取舍，对 JSON 的解析并不按照 JSON 标准。
+// 输出的字段按 tag 升序排列，与 JSON 的 key 顺序无关，因此可以由 TranscodeReaderToJson 边读边转译。
+// 转码受 DefaultLimits 限制，需要其它限制时使用 ToProtoOptions。
+func TranscodeToProto(p *proto.Encoder, j JsonLexer, msg *Message) error {
+	return defaultToProtoOptions.Transcode(p, j, msg)
+}
+
+// TranscodeToProtoInto 与 TranscodeToProto 相同，但把输出写入调用方提供的 dst 并返回写出的字节数，
+// dst 的长度不足时返回 io.ErrShortBuffer。按 SizeOfJson 的结果分配 dst 时输出恰好填满 dst。
+func TranscodeToProtoInto(dst []byte, j JsonLexer, msg *Message) (int, error) {
+	return defaultToProtoOptions.TranscodeInto(dst, j, msg)
+}
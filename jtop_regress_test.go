@@ -77,7 +77,8 @@ func TestRegress_MapDefaultEntryKept(t *testing.T) {
 			var buf proto.Encoder
 			it := jsonlit.NewIter([]byte(c.j))
 			it.Next()
-			if err := transJsonToMap(&buf, it, c.tag, c.entry); err != nil {
+			st := newJtopState(&defaultToProtoOptions)
+			if err := st.transJsonToMap(&buf, it, c.tag, c.entry); err != nil {
 				t.Fatal(err)
 			}
 			if got := hex.EncodeToString(buf.Bytes()); got != c.want {
@@ -1,44 +1,57 @@
 package jsonpb
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/vizee/jsonpb/jsonlit"
 	"github.com/vizee/jsonpb/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func skipJsonValueCase(j string) error {
-	it := jsonlit.NewIter([]byte(j))
-	tok, _ := it.Next()
-	err := skipJsonValue(it, tok)
-	if err != nil {
-		return err
+	// 内存中的输入由 JsonIter.SkipFrom 跳过，其它 JsonLexer 走递归跳过，两者结果应一致
+	var errs [2]error
+	for i, it := range []JsonLexer{jsonlit.NewIter([]byte(j)), jsonlit.NewReaderIter(strings.NewReader(j), 0)} {
+		tok, _ := it.Next()
+		st := newJtopState(&defaultToProtoOptions)
+		errs[i] = st.skipJsonValue(it, tok)
+		if errs[i] == nil && !it.EOF() {
+			errs[i] = fmt.Errorf("incomplete")
+		}
 	}
-	if !it.EOF() {
-		return fmt.Errorf("incomplete")
+	if !errors.Is(errs[1], errs[0]) {
+		return fmt.Errorf("iter error = %v, reader error = %v", errs[0], errs[1])
 	}
-	return nil
+	return errs[0]
 }
 
 func Test_skipJsonValue(t *testing.T) {
 	tests := []struct {
 		name    string
 		arg     string
-		wantErr bool
+		wantErr error
 	}{
-		{name: "array", arg: `[1,"hello",false,{"k1":"v1","k2":null}]`, wantErr: false},
-		{name: "object", arg: `{"a":1,"b":"hello","c":[1,"hello",false,{"k1":"v1","k2":"v2"}],"d":{"k1":"v1","k2":"v2"}}`, wantErr: false},
-		{name: "ignore_syntax", arg: `{"a" 1 "b" "hello" "c":[1 "hello" false {"k1":"v1","k2":"v2"}],"d":{"k1":"v1","k2":"v2"}}`, wantErr: false},
-		{name: "bad_token", arg: `:`, wantErr: true},
-		{name: "unterminated", arg: `{"k1":1,"k2":2`, wantErr: true},
-		{name: "unterminated_sub", arg: `{"k1":1,"k2":[`, wantErr: true},
-		{name: "unexpected_token", arg: `{"k1":1,"k2":[}`, wantErr: true},
+		{name: "array", arg: `[1,"hello",false,{"k1":"v1","k2":null}]`},
+		{name: "object", arg: `{"a":1,"b":"hello","c":[1,"hello",false,{"k1":"v1","k2":"v2"}],"d":{"k1":"v1","k2":"v2"}}`},
+		{name: "ignore_syntax", arg: `{"a" 1 "b" "hello" "c":[1 "hello" false {"k1":"v1","k2":"v2"}],"d":{"k1":"v1","k2":"v2"}}`},
+		{name: "bad_token", arg: `:`, wantErr: ErrUnexpectedToken},
+		{name: "unterminated", arg: `{"k1":1,"k2":2`, wantErr: io.ErrUnexpectedEOF},
+		{name: "unterminated_sub", arg: `{"k1":1,"k2":[`, wantErr: io.ErrUnexpectedEOF},
+		{name: "unterminated_array", arg: `[1,{"k":[]`, wantErr: io.ErrUnexpectedEOF},
+		{name: "unexpected_token", arg: `{"k1":1,"k2":[}`, wantErr: ErrUnexpectedToken},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := skipJsonValueCase(tt.arg); (err != nil) != tt.wantErr {
+			if err := skipJsonValueCase(tt.arg); !errors.Is(err, tt.wantErr) {
 				t.Errorf("skipJsonValueCase() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -47,7 +60,9 @@ func Test_skipJsonValue(t *testing.T) {
 
 func transJsonBytesCase(tag uint32, omitEmpty bool, s []byte) (string, error) {
 	var buf proto.Encoder
-	err := transJsonBytes(&buf, tag, omitEmpty, s)
+	st := newJtopState(&defaultToProtoOptions)
+	defer st.release()
+	err := st.transJsonBytes(&buf, tag, omitEmpty, s)
 	if err != nil {
 		return "", err
 	}
@@ -69,7 +84,14 @@ func Test_transJsonBytes(t *testing.T) {
 		{name: "empty", args: args{tag: 1, s: []byte(`""`)}, want: "0a00"},
 		{name: "omit_empty", args: args{tag: 1, omitEmpty: true, s: []byte(`""`)}, want: ""},
 		{name: "simple", args: args{tag: 1, s: []byte(`"aGVsbG8gd29ybGQ="`)}, want: "0a0b68656c6c6f20776f726c64"},
-		{name: "illegal_base64", args: args{tag: 1, s: []byte(`"aGVsbG8gd29ybGQ"`)}, want: "", wantErr: true},
+		{name: "unpadded", args: args{tag: 1, s: []byte(`"aGVsbG8gd29ybGQ"`)}, want: "0a0b68656c6c6f20776f726c64"},
+		{name: "url_safe", args: args{tag: 1, s: []byte(`"-_-_"`)}, want: "0a03fbffbf"},
+		{name: "std_alphabet", args: args{tag: 1, s: []byte(`"+/+/"`)}, want: "0a03fbffbf"},
+		{name: "url_safe_padded", args: args{tag: 1, s: []byte(`"-_8="`)}, want: "0a02fbff"},
+		{name: "url_safe_unpadded", args: args{tag: 1, s: []byte(`"-_8"`)}, want: "0a02fbff"},
+		{name: "mixed_alphabet", args: args{tag: 1, s: []byte(`"+_8="`)}, want: "", wantErr: true},
+		{name: "illegal_base64", args: args{tag: 1, s: []byte(`"aGVsbG8gd29ybGQ*"`)}, want: "", wantErr: true},
+		{name: "illegal_length", args: args{tag: 1, s: []byte(`"aGVsb"`)}, want: "", wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -85,9 +107,11 @@ func Test_transJsonBytes(t *testing.T) {
 	}
 }
 
-func transJsonStringCase(tag uint32, omitEmpty bool, s []byte) (string, error) {
+func transJsonStringCase(tag uint32, omitEmpty bool, s []byte, mode UTF8Mode) (string, error) {
 	var buf proto.Encoder
-	err := transJsonString(&buf, tag, omitEmpty, s)
+	st := newJtopState(&ToProtoOptions{UTF8: mode})
+	defer st.release()
+	err := st.transJsonString(&buf, &Field{Tag: tag, ValidateUTF8: true}, omitEmpty, s)
 	if err != nil {
 		return "", err
 	}
@@ -99,6 +123,7 @@ func Test_transJsonStringCase(t *testing.T) {
 		tag       uint32
 		omitEmpty bool
 		s         []byte
+		mode      UTF8Mode
 	}
 	tests := []struct {
 		name    string
@@ -111,10 +136,14 @@ func Test_transJsonStringCase(t *testing.T) {
 		{name: "simple", args: args{tag: 1, s: []byte(`"hello world"`)}, want: "0a0b68656c6c6f20776f726c64"},
 		{name: "escape", args: args{tag: 1, s: []byte(`"\u4f60\u597d"`)}, want: "0a06e4bda0e5a5bd"},
 		{name: "illegal_escape", args: args{tag: 1, s: []byte(`"\z"`)}, want: "", wantErr: true},
+		{name: "invalid_utf8", args: args{tag: 1, s: []byte("\"a\xffb\"")}, want: "", wantErr: true},
+		{name: "surrogate_utf8", args: args{tag: 1, s: []byte("\"\xed\xa0\x80\"")}, want: "", wantErr: true},
+		{name: "invalid_utf8_replace", args: args{tag: 1, s: []byte("\"a\xff\xfeb\""), mode: UTF8Replace}, want: "0a0561efbfbd62"},
+		{name: "invalid_utf8_passthrough", args: args{tag: 1, s: []byte("\"a\xffb\""), mode: UTF8Passthrough}, want: "0a0361ff62"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := transJsonStringCase(tt.args.tag, tt.args.omitEmpty, tt.args.s)
+			got, err := transJsonStringCase(tt.args.tag, tt.args.omitEmpty, tt.args.s, tt.args.mode)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("transJsonStringCase() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -192,7 +221,8 @@ func transJsonArrayFieldCase(j string, field *Field) (string, error) {
 	var buf proto.Encoder
 	it := jsonlit.NewIter([]byte(j))
 	it.Next()
-	err := transJsonArrayField(&buf, it, field)
+	st := newJtopState(&defaultToProtoOptions)
+	err := st.transJsonArrayField(&buf, it, field)
 	if err != nil {
 		return "", err
 	}
@@ -212,6 +242,7 @@ func Test_transJsonArrayFieldCase(t *testing.T) {
 	}{
 		{name: "empty", args: args{j: `[]`, field: &Field{Tag: 1, Kind: Int32Kind, Repeated: true}}, want: ""},
 		{name: "strings", args: args{j: `["hello","中文","🚀"]`, field: &Field{Tag: 2, Kind: StringKind, Repeated: true}}, want: "120568656c6c6f1206e4b8ade696871204f09f9a80"},
+		{name: "bytes_variants", args: args{j: `["-_8","+/8=","YWJj"]`, field: &Field{Tag: 2, Kind: BytesKind, Repeated: true}}, want: "1202fbff1202fbff1203616263"},
 		{name: "bytes", args: args{j: `["YWJj","aGVsbG8=","d29ybGQ="]`, field: &Field{Tag: 2, Kind: BytesKind, Repeated: true}}, want: "1203616263120568656c6c6f1205776f726c64"},
 		{name: "packed_double", args: args{j: `[0,1,2]`, field: &Field{Tag: 2, Kind: DoubleKind, Repeated: true}}, want: "12180000000000000000000000000000f03f0000000000000040"},
 		{name: "packed_float", args: args{j: `[0,1,2]`, field: &Field{Tag: 2, Kind: FloatKind, Repeated: true}}, want: "120c000000000000803f00000040"},
@@ -226,6 +257,12 @@ func Test_transJsonArrayFieldCase(t *testing.T) {
 		{name: "packed_sfixed32", args: args{j: `[0,1,2]`, field: &Field{Tag: 2, Kind: Sfixed32Kind, Repeated: true}}, want: "120c000000000100000002000000"},
 		{name: "packed_sfixed64", args: args{j: `[0,1,2]`, field: &Field{Tag: 2, Kind: Sfixed64Kind, Repeated: true}}, want: "1218000000000000000001000000000000000200000000000000"},
 		{name: "packed_bool", args: args{j: `[false,true,false]`, field: &Field{Tag: 2, Kind: BoolKind, Repeated: true}}, want: "1203000100"},
+		{name: "unpacked_int32", args: args{j: `[0,1,2]`, field: &Field{Tag: 2, Kind: Int32Kind, Repeated: true, Unpacked: true}}, want: "100010011002"},
+		{name: "unpacked_sint64", args: args{j: `[-1,1]`, field: &Field{Tag: 2, Kind: Sint64Kind, Repeated: true, Unpacked: true}}, want: "10011002"},
+		{name: "unpacked_fixed32", args: args{j: `[1]`, field: &Field{Tag: 2, Kind: Fixed32Kind, Repeated: true, Unpacked: true}}, want: "1501000000"},
+		{name: "unpacked_double", args: args{j: `[1]`, field: &Field{Tag: 2, Kind: DoubleKind, Repeated: true, Unpacked: true}}, want: "11000000000000f03f"},
+		{name: "unpacked_bool", args: args{j: `[true,false]`, field: &Field{Tag: 2, Kind: BoolKind, Repeated: true, Unpacked: true}}, want: "10011000"},
+		{name: "unpacked_empty", args: args{j: `[]`, field: &Field{Tag: 2, Kind: Int32Kind, Repeated: true, Unpacked: true}}, want: ""},
 		{name: "messages", args: args{j: `[{},null,{"name":"string","age":123},{"age":456}]`, field: &Field{Tag: 2, Kind: MessageKind, Ref: getTestSimpleMessage(), Repeated: true}}, want: "12001200120a0a06737472696e67107b120310c803"},
 		{name: "unterminated", args: args{j: `[0,1,2`, field: &Field{Tag: 2, Kind: Int32Kind, Repeated: true}}, wantErr: true},
 	}
@@ -247,7 +284,8 @@ func transJsonToMapCase(j string, tag uint32, entry *Message) (string, error) {
 	var buf proto.Encoder
 	it := jsonlit.NewIter([]byte(j))
 	it.Next()
-	err := transJsonToMap(&buf, it, tag, entry)
+	st := newJtopState(&defaultToProtoOptions)
+	err := st.transJsonToMap(&buf, it, tag, entry)
 	if err != nil {
 		return "", err
 	}
@@ -269,6 +307,7 @@ func Test_transJsonToMapCase(t *testing.T) {
 		{name: "empty", args: args{j: `{}`, tag: 2, entry: getTestMapEntry(StringKind, Int32Kind, nil)}, want: ""},
 		{name: "string_key", args: args{j: `{"a":1,"b":2}`, tag: 2, entry: getTestMapEntry(StringKind, Int32Kind, nil)}, want: "12050a0161100112050a01621002"},
 		{name: "numeric_key", args: args{j: `{"1":"a","2":"b"}`, tag: 2, entry: getTestMapEntry(Int32Kind, StringKind, nil)}, want: "1205080112016112050802120162"},
+		{name: "bytes_value", args: args{j: `{"a":"-_8","b":"+/8="}`, tag: 2, entry: getTestMapEntry(StringKind, BytesKind, nil)}, want: "12070a01611202fbff12070a01621202fbff"},
 		{name: "message_value", args: args{j: `{"v":{"name":"ok"}}`, tag: 2, entry: getTestMapEntry(StringKind, MessageKind, getTestSimpleMessage())}, want: "12090a017612040a026f6b"},
 		{name: "type_mismatched", args: args{j: `{"1":"a","2":"b"}`, tag: 2, entry: getTestMapEntry(BoolKind, StringKind, nil)}, wantErr: true},
 		{name: "unexpected_key", args: args{j: `{null`, tag: 2, entry: getTestMapEntry(StringKind, Int32Kind, nil)}, wantErr: true},
@@ -294,7 +333,8 @@ func transJsonObjectCase(j string, msg *Message) (string, error) {
 	var buf proto.Encoder
 	it := jsonlit.NewIter([]byte(j))
 	it.Next()
-	err := transJsonObject(&buf, it, msg)
+	st := newJtopState(&defaultToProtoOptions)
+	err := st.transJsonObject(&buf, it, msg)
 	if err != nil {
 		return "", err
 	}
@@ -359,3 +399,306 @@ func TestTranscodeToProto(t *testing.T) {
 		})
 	}
 }
+
+func TestTranscodeToProto_tagOrder(t *testing.T) {
+	// key 顺序与 tag 顺序相反（含子消息、map value 与 repeated 元素），输出仍按 tag 升序，与有序的 key 一致
+	const (
+		orderedJson  = `{"fdouble":123,"fint32":123,"fbool":true,"fstring":"okk","fmap1":{"k":1},"fmap2":{"u":{"name":"abc","age":23,"male":true},"v":null},"fsubmsg":{"name":"efg","age":23,"male":true},"fint32s":[1,2],"fitems":[{"name":"abc","age":12},null,{"name":"efg","age":23}]}`
+		shuffledJson = `{"fitems":[{"age":12,"name":"abc"},null,{"age":23,"name":"efg"}],"fint32s":[1],"fsubmsg":{"male":true,"age":23,"name":"efg"},"fmap2":{"u":{"male":true,"age":23,"name":"abc"},"v":null},"fmap1":{"k":1},"fstring":"okk","fint32s":[2],"fbool":true,"fint32":123,"fdouble":123}`
+	)
+	msg := getTestComplexMessage()
+	var want proto.Encoder
+	if err := TranscodeToProto(&want, jsonlit.NewIter([]byte(orderedJson)), msg); err != nil {
+		t.Fatal(err)
+	}
+	var got proto.Encoder
+	if err := TranscodeToProto(&got, jsonlit.NewIter([]byte(shuffledJson)), msg); err != nil {
+		t.Fatal(err)
+	}
+	// 同一字段的多段 packed 值保持原有顺序，不会合并
+	wantHex := strings.Replace(hex.EncodeToString(want.Bytes()), "9a01020102", "9a0101019a010102", 1)
+	if hex.EncodeToString(got.Bytes()) != wantHex {
+		t.Fatalf("TranscodeToProto() = %x, want %s", got.Bytes(), wantHex)
+	}
+	dst := make([]byte, got.Len())
+	n, err := TranscodeToProtoInto(dst, jsonlit.NewIter([]byte(shuffledJson)), msg)
+	if err != nil || !bytes.Equal(dst[:n], got.Bytes()) {
+		t.Fatalf("TranscodeToProtoInto() = %x, %v, want %x", dst[:n], err, got.Bytes())
+	}
+}
+
+func TestTranscodeToProto_reader(t *testing.T) {
+	const complexJson = `{"noexisted":{"a":[1,"x"]},"fdouble":123,"fstring":"okk","fbytes":"AQID","fmap1":{"k":1},"fmap2":{"u":{"name":"abc","age":23,"male":true},"v":null},"fsubmsg":{"name":"efg"},"fint32s":[1,2,3],"fitems":[{"name":"abc","age":12},null]}`
+	msg := getTestComplexMessage()
+	var want proto.Encoder
+	if err := TranscodeToProto(&want, jsonlit.NewIter([]byte(complexJson)), msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1, 5, 0} {
+		var got proto.Encoder
+		it := jsonlit.NewReaderIter(iotest.OneByteReader(strings.NewReader(complexJson)), size)
+		if err := TranscodeToProto(&got, it, msg); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("size=%d: got %x, want %x", size, got.Bytes(), want.Bytes())
+		}
+	}
+
+	readErr := errors.New("read failed")
+	it := jsonlit.NewReaderIter(io.MultiReader(strings.NewReader(`{"fstring":"ok`), iotest.ErrReader(readErr)), 0)
+	if err := TranscodeToProto(proto.NewEncoder(nil), it, msg); err != readErr {
+		t.Fatalf("TranscodeToProto() error = %v, want %v", err, readErr)
+	}
+
+	opts := ToProtoOptions{Limits: &Limits{MaxInputSize: 16}}
+	it = jsonlit.NewReaderIter(strings.NewReader(`{"fsubmsg":{"name":"abcdefghijklmn"}}`), 0)
+	if err := opts.Transcode(proto.NewEncoder(nil), it, msg); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Transcode() error = %v, want ErrLimitExceeded", err)
+	}
+}
+
+// countReader 记录从 r 读取的字节数
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestTranscodeToProto_readerLimits(t *testing.T) {
+	msg := getTestComplexMessage()
+	const huge = 8 << 20
+	tests := []struct {
+		name   string
+		j      string
+		limits Limits
+		limit  string
+	}{
+		{name: "string", j: `{"fstring":"` + strings.Repeat("a", huge) + `"}`, limits: Limits{MaxInputSize: 1024, MaxStringLen: 16}, limit: "string length"},
+		{name: "string_input", j: `{"fstring":"` + strings.Repeat("a", huge) + `"}`, limits: Limits{MaxInputSize: 1024}, limit: "input size"},
+		{name: "number", j: `{"fdouble":1` + strings.Repeat("0", huge) + `}`, limits: Limits{MaxInputSize: 1024}, limit: "input size"},
+		{name: "unknown", j: `{"noexisted":"` + strings.Repeat("a", huge) + `"}`, limits: Limits{MaxStringLen: 16}, limit: "string length"},
+		{name: "fields", j: `{` + strings.Repeat(`"fint32":1,`, huge/11) + `"fint32":1}`, limits: Limits{MaxInputSize: 1024}, limit: "input size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &countReader{r: strings.NewReader(tt.j)}
+			opts := ToProtoOptions{Limits: &tt.limits}
+			err := opts.Transcode(proto.NewEncoder(nil), jsonlit.NewReaderIter(r, 0), msg)
+			var le *LimitError
+			if !errors.As(err, &le) || le.Limit != tt.limit {
+				t.Fatalf("Transcode() error = %v, want %s limit", err, tt.limit)
+			}
+			if r.n > 16<<10 {
+				t.Fatalf("read %d bytes before the limit error", r.n)
+			}
+		})
+	}
+}
+
+func TestToProtoOptions_Deterministic(t *testing.T) {
+	msg, md := getTestDetMessage()
+	tests := []struct {
+		name string
+		j    string
+		ref  string // protojson 不接受重复 key，非空时用 ref 生成期望输出
+	}{
+		{name: "empty", j: `{}`},
+		{name: "order", j: `{"score":1.5,"name":"abc","nums":[3,0,-1],"child":{"nums":[],"name":"x"}}`},
+		{name: "zero_values", j: `{"name":"","score":0,"nums":[0],"child":{}}`},
+		{name: "map_keys", j: `{"ids":{"10":"a","-2":"b","0":"","-9223372036854775808":"c","3":"d"},"flags":{"18446744073709551615":true,"0":false,"7":true}}`},
+		{name: "string_keys", j: `{"subs":{"b":{"name":"b"},"a":{},"é":{"score":2},"A":{"subs":{"y":{},"x":{"nums":[1]}}}}}`},
+		{name: "duplicate", j: `{"name":"a","score":1,"name":"b","ids":{"1":"x","1":"y"},"child":{"name":"c"},"child":{"score":3}}`, ref: `{"name":"b","score":1,"ids":{"1":"y"},"child":{"score":3}}`},
+	}
+	opts := ToProtoOptions{Deterministic: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := tt.ref
+			if ref == "" {
+				ref = tt.j
+			}
+			m := dynamicpb.NewMessage(md)
+			if err := protojson.Unmarshal([]byte(ref), m); err != nil {
+				t.Fatal(err)
+			}
+			want, err := gproto.MarshalOptions{Deterministic: true}.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got proto.Encoder
+			if err := opts.Transcode(&got, jsonlit.NewIter([]byte(tt.j)), msg); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Fatalf("Transcode() = %x, want %x", got.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestToProtoOptions_UTF8(t *testing.T) {
+	j := []byte("{\"fmap1\":{\"k\xff\":1}}")
+	const want = "820106" + "0a026bff1001"
+	// 未设置 ValidateUTF8 的字段不做检查
+	var p proto.Encoder
+	if err := TranscodeToProto(&p, jsonlit.NewIter(j), getTestComplexMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(p.Bytes()); got != want {
+		t.Fatalf("TranscodeToProto() = %s, want %s", got, want)
+	}
+
+	msg := NewMessage("", []Field{
+		{Name: "fmap1", Tag: 16, Kind: MapKind, Ref: NewMessage("", []Field{
+			{Tag: 1, Kind: StringKind, ValidateUTF8: true},
+			{Tag: 2, Kind: Int32Kind},
+		}, true, true)},
+	}, true, true)
+	if err := TranscodeToProto(proto.NewEncoder(nil), jsonlit.NewIter(j), msg); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("TranscodeToProto() error = %v, want ErrInvalidUTF8", err)
+	}
+	opts := ToProtoOptions{UTF8: UTF8Passthrough}
+	p.Clear()
+	if err := opts.Transcode(&p, jsonlit.NewIter(j), msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(p.Bytes()); got != want {
+		t.Fatalf("Transcode() = %s, want %s", got, want)
+	}
+}
+
+func TestTranscodeToProto_nested(t *testing.T) {
+	node := &Message{Name: "Node"}
+	node.Fields = []Field{
+		{Name: "name", Tag: 1, Kind: StringKind},
+		{Name: "child", Tag: 2, Kind: MessageKind, Ref: node},
+		{Name: "empty", Tag: 3, Kind: MessageKind, Ref: node},
+	}
+	node.BakeTagIndex()
+	node.BakeNameIndex()
+
+	for _, size := range []int{1, 100, 200, 20000} {
+		name := strings.Repeat("x", size)
+		j := `{"name":"` + name + `","empty":{}}`
+		var want proto.Encoder
+		want.EmitString(1, name)
+		for i := 0; i < 5; i++ {
+			j = `{"child":` + j + `,"empty":{}}`
+			var outer proto.Encoder
+			outer.EmitBytes(2, want.Bytes())
+			want = outer
+		}
+		var got proto.Encoder
+		if err := TranscodeToProto(&got, jsonlit.NewIter([]byte(j)), node); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("size %d: got %x, want %x", size, got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestTranscodeToProto_syntaxError(t *testing.T) {
+	tests := []struct {
+		name   string
+		j      string
+		want   string
+		reason error
+	}{
+		{name: "unterminated", j: "{\n  \"fstring\": \"abc", want: "line 2 col 14: unterminated string", reason: jsonlit.ErrUnterminatedString},
+		{name: "literal", j: "{\"fbool\":\n\ttrue,\n\"fsubmsg\":nul}", want: "line 3 col 11: invalid literal", reason: jsonlit.ErrInvalidLiteral},
+		{name: "number", j: `{"fint32":-}`, want: "line 1 col 11: invalid number", reason: jsonlit.ErrInvalidNumber},
+		{name: "char", j: `{"fint32s":[1,@]}`, want: "line 1 col 15: invalid character", reason: jsonlit.ErrInvalidChar},
+		{name: "token", j: "{\"fint32\":}", want: "line 1 col 11: unexpected token"},
+	}
+	msg := getTestComplexMessage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexers := []JsonLexer{
+				jsonlit.NewIter([]byte(tt.j)),
+				jsonlit.NewReaderIter(iotest.OneByteReader(strings.NewReader(tt.j)), 1),
+			}
+			for _, j := range lexers {
+				err := TranscodeToProto(proto.NewEncoder(nil), j, msg)
+				var serr *SyntaxError
+				if !errors.As(err, &serr) || !errors.Is(err, ErrUnexpectedToken) || serr.Reason != tt.reason {
+					t.Fatalf("TranscodeToProto() error = %v, want SyntaxError with reason %v", err, tt.reason)
+				}
+				if err.Error() != tt.want {
+					t.Fatalf("TranscodeToProto() error = %q, want %q", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscodeToProto_number(t *testing.T) {
+	tests := []struct {
+		name    string
+		j       string
+		want    string
+		wantErr error
+	}{
+		{name: "exponent_plus", j: `{"fdouble":1e+2}`, want: "090000000000005940"},
+		{name: "float_for_double", j: `{"fdouble":1.0}`, want: "09000000000000f03f"},
+		{name: "float_for_int", j: `{"fint32":1.0}`, wantErr: ErrNotInteger},
+		{name: "exponent_for_int", j: `{"fuint64":1e2}`, wantErr: ErrNotInteger},
+		{name: "float_in_packed", j: `{"fint32s":[1,2.5]}`, wantErr: ErrNotInteger},
+		{name: "float_map_key", j: `{"fmap3":{"1.5":"x"}}`, wantErr: ErrNotInteger},
+		{name: "bad_grammar", j: `{"fint32":1-2}`, wantErr: jsonlit.ErrInvalidNumber},
+		{name: "leading_zero", j: `{"fdouble":01}`, wantErr: jsonlit.ErrInvalidNumber},
+	}
+	msg := getTestComplexMessage()
+	msg.Fields = append(msg.Fields, Field{Name: "fmap3", Kind: MapKind, Tag: 21, Ref: getTestMapEntry(Int64Kind, StringKind, nil)})
+	msg.BakeTagIndex()
+	msg.BakeNameIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p proto.Encoder
+			err := TranscodeToProto(&p, jsonlit.NewIter([]byte(tt.j)), msg)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("TranscodeToProto() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(p.Bytes()); got != tt.want {
+				t.Fatalf("TranscodeToProto() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscodeToProto_relaxed(t *testing.T) {
+	const relaxed = `// 人工编写的配置
+{
+	fstring: 'say "hi"', /* 单引号字符串 */
+	fint32: -0x10,
+	fsubmsg: {name: 'sub', age: 0x1,},
+	fint32s: [1, 2, 3,],
+	fmap1: {a: 1, 'b c': 2,},
+}`
+	const strict = `{"fstring":"say \"hi\"","fint32":-16,"fsubmsg":{"name":"sub","age":1},"fint32s":[1,2,3],"fmap1":{"a":1,"b c":2}}`
+	msg := getTestComplexMessage()
+	var want proto.Encoder
+	if err := TranscodeToProto(&want, jsonlit.NewIter([]byte(strict)), msg); err != nil {
+		t.Fatal(err)
+	}
+	it := jsonlit.NewIter([]byte(relaxed))
+	it.SetRelaxed(true)
+	var got proto.Encoder
+	if err := TranscodeToProto(&got, it, msg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Fatalf("TranscodeToProto() = %x, want %x", got.Bytes(), want.Bytes())
+	}
+}
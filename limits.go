@@ -0,0 +1,93 @@
+package jsonpb
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Limits 限制转码时的嵌套深度与各类尺寸，用于防御恶意输入。各项为 0 表示不限制。
+type Limits struct {
+	// MaxDepth 最大嵌套深度，JSON 的对象/数组与 protobuf 的子消息各算一层
+	MaxDepth int
+	// MaxInputSize 输入总字节数
+	MaxInputSize int
+	// MaxRepeated 单个 repeated 字段的元素个数
+	MaxRepeated int
+	// MaxMapEntries 单个 map 字段的 entry 个数
+	MaxMapEntries int
+	// MaxStringLen 单个 string/bytes 值的字节数（JSON 侧按未转义的原始长度计）。
+	// 从 io.Reader 读取 JSON 时同时限制单个 token 的长度，见 tokenLimit
+	MaxStringLen int
+}
+
+// minTokenLimit 是从 io.Reader 读取 JSON 时单个 token 长度上限的下限，保证较长的数值字面量不受 MaxStringLen 影响
+const minTokenLimit = 1024
+
+// DefaultLimits 是未指定 Limits 时使用的限制，深度与 google.golang.org/protobuf 的默认递归限制一致。
+var DefaultLimits = Limits{
+	MaxDepth: 10000,
+}
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError 描述超出的具体限制，可用 errors.Is(err, ErrLimitExceeded) 判断。
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return e.Limit + " limit exceeded (max " + strconv.Itoa(e.Max) + ")"
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+func (l *Limits) checkDepth(depth int) error {
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &LimitError{Limit: "depth", Max: l.MaxDepth}
+	}
+	return nil
+}
+
+func (l *Limits) checkInputSize(n int) error {
+	if l.MaxInputSize > 0 && n > l.MaxInputSize {
+		return &LimitError{Limit: "input size", Max: l.MaxInputSize}
+	}
+	return nil
+}
+
+func (l *Limits) checkRepeated(n int) error {
+	if l.MaxRepeated > 0 && n > l.MaxRepeated {
+		return &LimitError{Limit: "repeated", Max: l.MaxRepeated}
+	}
+	return nil
+}
+
+func (l *Limits) checkMapEntries(n int) error {
+	if l.MaxMapEntries > 0 && n > l.MaxMapEntries {
+		return &LimitError{Limit: "map entries", Max: l.MaxMapEntries}
+	}
+	return nil
+}
+
+// tokenLimit 返回从 io.Reader 读取 JSON 时单个 token 的长度上限（用作 ReaderIter 的最大窗口）与超出时报告的错误，0 表示不限制。
+// 字符串 token 受 MaxStringLen（含引号）限制，任何 token 都受 MaxInputSize 限制，超长的 token 因此不会被整体读入内存。
+func (l *Limits) tokenLimit() (int, error) {
+	n, err := 0, error(nil)
+	if l.MaxStringLen > 0 {
+		n, err = max(l.MaxStringLen+2, minTokenLimit), &LimitError{Limit: "string length", Max: l.MaxStringLen}
+	}
+	if l.MaxInputSize > 0 && (n == 0 || l.MaxInputSize < n) {
+		n, err = l.MaxInputSize, &LimitError{Limit: "input size", Max: l.MaxInputSize}
+	}
+	return n, err
+}
+
+func (l *Limits) checkStringLen(n int) error {
+	if l.MaxStringLen > 0 && n > l.MaxStringLen {
+		return &LimitError{Limit: "string length", Max: l.MaxStringLen}
+	}
+	return nil
+}
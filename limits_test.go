@@ -0,0 +1,93 @@
+package jsonpb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func getTestRecursiveMessage() *Message {
+	msg := NewMessage("Node", []Field{
+		{Name: "name", Tag: 1, Kind: StringKind, Omit: OmitEmpty},
+		{Name: "child", Tag: 2, Kind: MessageKind, Omit: OmitEmpty},
+		{Name: "ids", Tag: 3, Kind: Int32Kind, Repeated: true, Omit: OmitEmpty},
+		{Name: "m", Tag: 4, Kind: MapKind, Ref: getTestMapEntry(StringKind, Int32Kind, nil), Omit: OmitEmpty},
+	}, true, true)
+	msg.Fields[1].Ref = msg
+	return msg
+}
+
+func TestToProtoOptions_Limits(t *testing.T) {
+	tests := []struct {
+		name    string
+		j       string
+		limits  Limits
+		wantErr bool
+	}{
+		{name: "depth_ok", j: `{"child":{"child":{}}}`, limits: Limits{MaxDepth: 3}},
+		{name: "depth", j: `{"child":{"child":{"child":{}}}}`, limits: Limits{MaxDepth: 3}, wantErr: true},
+		{name: "skip_depth", j: `{"unknown":[[[[]]]]}`, limits: Limits{MaxDepth: 4}, wantErr: true},
+		{name: "input_size", j: `{"name":"abcdef"}`, limits: Limits{MaxInputSize: 10}, wantErr: true},
+		{name: "repeated_ok", j: `{"ids":[1,2,3]}`, limits: Limits{MaxRepeated: 3}},
+		{name: "repeated", j: `{"ids":[1,2,3,4]}`, limits: Limits{MaxRepeated: 3}, wantErr: true},
+		{name: "map_entries", j: `{"m":{"a":1,"b":2}}`, limits: Limits{MaxMapEntries: 1}, wantErr: true},
+		{name: "string_len_ok", j: `{"name":"abc"}`, limits: Limits{MaxStringLen: 3}},
+		{name: "string_len", j: `{"name":"abcd"}`, limits: Limits{MaxStringLen: 3}, wantErr: true},
+	}
+	msg := getTestRecursiveMessage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ToProtoOptions{Limits: &tt.limits}
+			err := opts.Transcode(proto.NewEncoder(nil), jsonlit.NewIter([]byte(tt.j)), msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transcode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Transcode() error = %v, want ErrLimitExceeded", err)
+			}
+		})
+	}
+}
+
+func TestToJsonOptions_Limits(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       string
+		limits  Limits
+		wantErr bool
+	}{
+		// child{child{}}
+		{name: "depth_ok", p: "12021200", limits: Limits{MaxDepth: 3}},
+		{name: "depth", p: "120412021200", limits: Limits{MaxDepth: 3}, wantErr: true},
+		{name: "input_size", p: "0a03616263", limits: Limits{MaxInputSize: 4}, wantErr: true},
+		{name: "repeated_ok", p: "1a03010203", limits: Limits{MaxRepeated: 3}},
+		{name: "repeated", p: "1a0301020318" + "04", limits: Limits{MaxRepeated: 3}, wantErr: true},
+		{name: "map_entries", p: "22050a0161100122050a01621002", limits: Limits{MaxMapEntries: 1}, wantErr: true},
+		{name: "string_len", p: "0a0461626364", limits: Limits{MaxStringLen: 3}, wantErr: true},
+	}
+	msg := getTestRecursiveMessage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ToJsonOptions{Limits: &tt.limits}
+			err := opts.Transcode(&JsonBuilder{}, proto.NewDecoder(decodeBytes(tt.p)), msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transcode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Transcode() error = %v, want ErrLimitExceeded", err)
+			}
+		})
+	}
+}
+
+func TestDefaultLimits_deepNesting(t *testing.T) {
+	n := DefaultLimits.MaxDepth + 1
+	j := `{"x":` + strings.Repeat("[", n) + strings.Repeat("]", n) + `}`
+	err := TranscodeToProto(proto.NewEncoder(nil), jsonlit.NewIter([]byte(j)), getTestSimpleMessage())
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("TranscodeToProto() error = %v, want ErrLimitExceeded", err)
+	}
+}
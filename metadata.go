@@ -22,6 +22,8 @@ const (
 	BytesKind
 	MapKind
 	MessageKind
+	// GroupKind 是 proto2 group，Ref 指向 group 的消息结构，JSON 侧与 MessageKind 一样为对象
+	GroupKind
 )
 
 func IsNumericKind(k Kind) bool {
@@ -175,5 +177,20 @@ type Field struct {
 	Ref      *Message
 	Tag      uint32
 	Repeated bool
+	// Unpacked 只对 repeated 数值/bool 字段有效：json->proto 时每个元素单独带 tag 写出，
+	// 用于兼容未开启 packed 的 proto2 字段；默认（false）写出为 packed。
+	// proto->json 方向两种编码都接受，不受影响。
+	Unpacked bool
 	Omit     OmitRule
+	// Default 是非重复标量字段缺失时 proto->json 输出的 JSON 字面量（如 `5`、`"abc"`），
+	// 对应 proto2 的 [default = ...]；为空时使用类型的默认值。
+	// 设置了 Default 的字段有 presence，json->proto 时零值也会写出。
+	Default string
+	// Required 对应 proto2 的 required：两个方向缺失该字段时都返回 RequiredFieldError，
+	// json->proto 时零值也会写出，null 视为未设置。
+	Required bool
+	// ValidateUTF8 对应 proto3 string 字段的 UTF-8 要求：为 true 时 json->proto 按选项的 UTF8 处理非法 UTF-8，
+	// 默认（false）与 proto2 一致，不做检查。proto->json 的输出必须是合法 JSON，总是检查，不看这个字段。
+	// NewMessageFromDescriptor 为 proto3 的 string 字段（含 map 的 key/value）设置。
+	ValidateUTF8 bool
 }
@@ -0,0 +1,31 @@
+package jsonpb
+
+import (
+	"sync"
+
+	"github.com/vizee/jsonpb/internal/bufpool"
+)
+
+// jsonBuilderPools 按缓冲容量分级，级别 i 中 JsonBuilder 的容量不小于 256<<i
+var jsonBuilderPools [bufpool.NumClasses]sync.Pool
+
+// AcquireJsonBuilder 从池中取得一个容量至少为 size 的空 JsonBuilder，用完后调用 Release 归还。
+// size 可以用 EstimateJsonSize 粗略估计。
+func AcquireJsonBuilder(size int) *JsonBuilder {
+	class, c := bufpool.AcquireClass(size)
+	if class >= 0 {
+		if b, _ := jsonBuilderPools[class].Get().(*JsonBuilder); b != nil {
+			return b
+		}
+	}
+	return &JsonBuilder{buf: make([]byte, 0, c)}
+}
+
+// Release 清空 b 并放回池中，之后不能再使用 b 及 String 返回过的内容（IntoBytes 取走的缓冲不受影响）。
+// 容量超过 1MiB 的缓冲不放回池中，避免偶发的大消息长期占用内存。
+func (b *JsonBuilder) Release() {
+	*b = JsonBuilder{buf: b.buf[:0]}
+	if class := bufpool.ReleaseClass(cap(b.buf)); class >= 0 {
+		jsonBuilderPools[class].Put(b)
+	}
+}
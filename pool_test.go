@@ -0,0 +1,41 @@
+package jsonpb
+
+import (
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func TestAcquireJsonBuilder(t *testing.T) {
+	for _, size := range []int{0, 100, 1000, 5 << 20} {
+		b := AcquireJsonBuilder(size)
+		if b.Len() != 0 || cap(b.buf) < size {
+			t.Fatalf("AcquireJsonBuilder(%d): len %d cap %d", size, b.Len(), cap(b.buf))
+		}
+		b.AppendString(`{"a":1}`)
+		b.Release()
+		if b.Len() != 0 {
+			t.Fatal("Release did not clear the builder")
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	msg := getTestComplexMessage()
+	for _, data := range []string{benchComplexJson, `{}`, `{"fitems":[{},{},{},{}],"fmap1":{"a":1,"b":2,"c":3}}`} {
+		enc := proto.AcquireEncoder(len(data))
+		if err := TranscodeToProto(enc, jsonlit.NewIter([]byte(data)), msg); err != nil {
+			t.Fatal(err)
+		}
+		pb := enc.Bytes()
+
+		// 估计只是提示，结果不一定能放入预留的容量
+		j := AcquireJsonBuilder(EstimateJsonSize(msg, len(pb)))
+		if err := TranscodeToJson(j, proto.NewDecoder(pb), msg); err != nil {
+			t.Fatal(err)
+		}
+		j.Release()
+		enc.Release()
+	}
+}
@@ -1,3 +1,10 @@
+// Package proto 提供 protobuf wire 格式的编码器 Encoder 与解码器 Decoder/ReaderDecoder。
+//
+// Decoder 的读取方法（ReadVarint、ReadTag、ReadBytes、ReadValue、SkipField 等）返回 int 错误码而不是 error：
+// 它们位于转码的热路径上，错误码是与 protowire 一致的负数，成功时为 0，不产生接口值与分配，调用方只需比较 < 0。
+// 需要 error 时，ReadField 一次读取 tag 与值并返回 *Error；其它方法失败后把错误码交给 ErrorOf，
+// 得到的 *Error 带有失败位置的 Offset（失败的读取不前移）与正在读取的字段，可用 errors.Is 判断原因。
+// ReaderDecoder 从 io.Reader 读取，本身就有 I/O 错误，因此直接返回 error。
 package proto
 
 import "google.golang.org/protobuf/encoding/protowire"
@@ -5,12 +12,30 @@ import "google.golang.org/protobuf/encoding/protowire"
 type Decoder struct {
 	buf []byte
 	i   int
+	// base 是 buf 在整个输入中的偏移，见 NewDecoderAt
+	base int64
+}
+
+// Len 返回尚未读取的字节数。
+func (d *Decoder) Len() int {
+	return len(d.buf) - d.i
 }
 
 func (d *Decoder) EOF() bool {
 	return d.i >= len(d.buf)
 }
 
+// Offset 返回下一个未读字节在输入中的偏移（含 NewDecoderAt 的 base）。
+func (d *Decoder) Offset() int {
+	return int(d.base) + d.i
+}
+
+// ErrorOf 把其它方法返回的 int 错误码 code 转换为 *Error，Offset 为当前位置（失败的读取不会前移）。
+// tag 与 wire 为正在读取的字段，读取 tag 失败时传 0。
+func (d *Decoder) ErrorOf(code int, tag uint32, wire protowire.Type) error {
+	return &Error{Offset: d.base + int64(d.i), Tag: tag, Wire: wire, Err: codeError(code)}
+}
+
 func (d *Decoder) ReadVarint() (uint64, int) {
 	v, n := protowire.ConsumeVarint(d.buf[d.i:])
 	if n < 0 {
@@ -70,6 +95,81 @@ func (d *Decoder) ReadBytes() ([]byte, int) {
 	return v, 0
 }
 
+// SkipField 跳过 tag 之后类型为 wire 的字段值，支持 varint、fixed32/64、bytes 与（嵌套的）group，
+// 用于跳过未知字段。
+func (d *Decoder) SkipField(tag uint32, wire protowire.Type) int {
+	n := protowire.ConsumeFieldValue(protowire.Number(tag), wire, d.buf[d.i:])
+	if n < 0 {
+		return n
+	}
+	d.i += n
+	return 0
+}
+
+// ReadGroup 读取 StartGroup tag 之后的 group 内容，tag 为该 group 的字段号。
+// 返回的内容不含 EndGroup tag，EndGroup 的字段号必须与 tag 一致。
+func (d *Decoder) ReadGroup(tag uint32) ([]byte, int) {
+	v, n := protowire.ConsumeGroup(protowire.Number(tag), d.buf[d.i:])
+	if n < 0 {
+		return nil, n
+	}
+	d.i += n
+	return v, 0
+}
+
+// Value 是一个字段值：varint 与 fixed32/64 存于 X，bytes 与 group 的内容存于 S。
+// Off 是 S 在输入中的偏移，解码嵌套消息时传给 NewDecoderAt，错误的 Offset 即为整个输入中的偏移。
+type Value struct {
+	X   uint64
+	S   []byte
+	Off int64
+}
+
+// ReadValue 读取 tag 之后类型为 wire 的字段值，group 的内容不含 EndGroup tag。
+func (d *Decoder) ReadValue(tag uint32, wire protowire.Type) (v Value, e int) {
+	switch wire {
+	case protowire.VarintType:
+		v.X, e = d.ReadVarint()
+	case protowire.Fixed32Type:
+		var t uint32
+		t, e = d.ReadFixed32()
+		v.X = uint64(t)
+	case protowire.Fixed64Type:
+		v.X, e = d.ReadFixed64()
+	case protowire.BytesType:
+		v.S, e = d.ReadBytes()
+		v.Off = d.base + int64(d.i-len(v.S))
+	case protowire.StartGroupType:
+		v.Off = d.base + int64(d.i)
+		v.S, e = d.ReadGroup(tag)
+	case protowire.EndGroupType:
+		e = errCodeEndGroup
+	default:
+		e = errCodeReserved
+	}
+	return
+}
+
+// ReadField 读取下一个字段的 tag 与值，出错时返回 *Error。
+// 其它方法返回 int 错误码以避免热路径上的开销，需要 error 时可使用本方法或 ErrorOf。
+func (d *Decoder) ReadField() (tag uint32, wire protowire.Type, v Value, err error) {
+	tag, wire, e := d.ReadTag()
+	if e < 0 {
+		return 0, 0, v, d.ErrorOf(e, 0, 0)
+	}
+	v, e = d.ReadValue(tag, wire)
+	if e < 0 {
+		return 0, 0, v, d.ErrorOf(e, tag, wire)
+	}
+	return tag, wire, v, nil
+}
+
 func NewDecoder(buf []byte) *Decoder {
 	return &Decoder{buf: buf}
 }
+
+// NewDecoderAt 返回从 buf 读取的 Decoder，buf 位于整个输入的 base 偏移处（通常是 Value.Off），
+// Offset 与错误的 Offset 都加上 base。
+func NewDecoderAt(buf []byte, base int64) *Decoder {
+	return &Decoder{buf: buf, base: base}
+}
@@ -1,6 +1,11 @@
 package proto
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
 	"testing"
 
 	"google.golang.org/protobuf/encoding/protowire"
@@ -35,3 +40,193 @@ func TestDecode(t *testing.T) {
 	assert2(t, readTag, 4, protowire.VarintType)
 	assert2(t, dec.ReadZigzag, -233, 0)
 }
+
+func TestDecode_group(t *testing.T) {
+	raw := []byte{59, 8, 1, 19, 20, 60, 8, 2}
+	dec := NewDecoder(raw)
+	tag, wire, _ := dec.ReadTag()
+	if tag != 7 || wire != protowire.StartGroupType {
+		t.Fatal("ReadTag", tag, wire)
+	}
+	data, n := dec.ReadGroup(tag)
+	if n < 0 || string(data) != "\x08\x01\x13\x14" {
+		t.Fatal("ReadGroup", data, n)
+	}
+	readTag := func() (uint32, protowire.Type) {
+		a, b, _ := dec.ReadTag()
+		return a, b
+	}
+	assert2(t, readTag, 1, protowire.VarintType)
+	assert2(t, dec.ReadVarint, 2, 0)
+
+	// EndGroup 字段号不匹配
+	if _, n := NewDecoder([]byte{8, 1, 28}).ReadGroup(1); n >= 0 {
+		t.Fatal("ReadGroup mismatch", n)
+	}
+}
+
+func TestDecoder_SkipField(t *testing.T) {
+	// varint、fixed64、bytes、fixed32、嵌套 group，最后是 tag=1 的 varint
+	raw := []byte{8, 233, 1, 17, 1, 2, 3, 4, 5, 6, 7, 8, 26, 2, 1, 2, 37, 1, 2, 3, 4, 43, 51, 8, 1, 52, 58, 1, 0, 44, 8, 5}
+	dec := NewDecoder(raw)
+	for i := 0; i < 5; i++ {
+		tag, wire, e := dec.ReadTag()
+		if e < 0 {
+			t.Fatal("ReadTag", e)
+		}
+		if e := dec.SkipField(tag, wire); e < 0 {
+			t.Fatal("SkipField", tag, wire, e)
+		}
+	}
+	readTag := func() (uint32, protowire.Type) {
+		a, b, _ := dec.ReadTag()
+		return a, b
+	}
+	assert2(t, readTag, 1, protowire.VarintType)
+	assert2(t, dec.ReadVarint, 5, 0)
+
+	for _, tt := range []struct {
+		raw  []byte
+		tag  uint32
+		wire protowire.Type
+	}{
+		{raw: []byte{0x80}, tag: 1, wire: protowire.VarintType},
+		{raw: []byte{1, 2}, tag: 1, wire: protowire.Fixed32Type},
+		{raw: []byte{3, 1}, tag: 1, wire: protowire.BytesType},
+		{raw: []byte{8, 1}, tag: 1, wire: protowire.StartGroupType},
+		{raw: []byte{}, tag: 1, wire: protowire.EndGroupType},
+	} {
+		if e := NewDecoder(tt.raw).SkipField(tt.tag, tt.wire); e >= 0 {
+			t.Fatal("SkipField", tt.raw, tt.wire)
+		}
+	}
+}
+
+func TestDecoder_ReadValue(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		tag  uint32
+		wire protowire.Type
+		want Value
+		code int
+	}{
+		{name: "varint", raw: "7b", wire: protowire.VarintType, want: Value{X: 123}},
+		{name: "fixed32", raw: "7b000000", wire: protowire.Fixed32Type, want: Value{X: 123}},
+		{name: "fixed64", raw: "7b00000000000000", wire: protowire.Fixed64Type, want: Value{X: 123}},
+		{name: "bytes", raw: "036f6b6b", wire: protowire.BytesType, want: Value{S: []byte("okk"), Off: 1}},
+		{name: "group", raw: "08011a0013140c", tag: 1, wire: protowire.StartGroupType, want: Value{S: []byte{8, 1, 26, 0, 19, 20}}},
+		{name: "group_end_mismatch", raw: "08011c", tag: 1, wire: protowire.StartGroupType, code: errCodeEndGroup},
+		{name: "group_truncated", raw: "0801", tag: 1, wire: protowire.StartGroupType, code: errCodeTruncated},
+		{name: "end_group", raw: "", wire: protowire.EndGroupType, code: errCodeEndGroup},
+		{name: "reserved", raw: "", wire: 6, code: errCodeReserved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.raw)
+			got, code := NewDecoder(raw).ReadValue(tt.tag, tt.wire)
+			if !reflect.DeepEqual(got, tt.want) || code != tt.code {
+				t.Errorf("ReadValue() = %v, %d, want %v, %d", got, code, tt.want, tt.code)
+			}
+		})
+	}
+}
+
+func TestDecoder_ReadField(t *testing.T) {
+	dec := NewDecoder([]byte{8, 1, 18, 5, 1})
+	tag, wire, v, err := dec.ReadField()
+	if err != nil || tag != 1 || wire != protowire.VarintType || v.X != 1 {
+		t.Fatal("ReadField", tag, wire, v, err)
+	}
+	_, _, _, err = dec.ReadField()
+	var perr *Error
+	if !errors.As(err, &perr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("ReadField", err)
+	}
+	if perr.Offset != 3 || perr.Tag != 2 || perr.Wire != protowire.BytesType {
+		t.Errorf("ReadField() error = %+v", perr)
+	}
+	if got, want := err.Error(), "offset 3 field 2 (wire type 2): unexpected EOF"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	dec = NewDecoder([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	if _, _, _, err := dec.ReadField(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("ReadField() error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestNewDecoderAt(t *testing.T) {
+	// 第二个字段是 bytes，内容中 tag=2 的 varint 被截断
+	dec := NewDecoder([]byte{8, 1, 18, 2, 16, 0x80, 27, 8, 1, 28})
+	if _, _, _, err := dec.ReadField(); err != nil {
+		t.Fatal(err)
+	}
+	_, _, v, err := dec.ReadField()
+	if err != nil || v.Off != 4 {
+		t.Fatal("ReadField", v, err)
+	}
+	sub := NewDecoderAt(v.S, v.Off)
+	_, _, _, err = sub.ReadField()
+	var perr *Error
+	if !errors.As(err, &perr) || perr.Offset != 5 || perr.Tag != 2 {
+		t.Fatalf("ReadField() error = %v", err)
+	}
+	if _, _, v, err := dec.ReadField(); err != nil || v.Off != 7 || !bytes.Equal(v.S, []byte{8, 1}) {
+		t.Fatal("ReadField group", v, err)
+	}
+	if dec.Offset() != 10 || NewDecoderAt(nil, 7).Offset() != 7 {
+		t.Fatal("Offset", dec.Offset())
+	}
+}
+
+func TestDecoder_ReadValueError(t *testing.T) {
+	// 值位于整个输入的偏移 10 处，错误的 Offset 与成功时的 Off 都计入 base
+	const base = 10
+	tests := []struct {
+		name    string
+		raw     string
+		tag     uint32
+		wire    protowire.Type
+		want    Value
+		wantErr error
+	}{
+		{name: "bytes", raw: "036f6b6b", tag: 2, wire: protowire.BytesType, want: Value{S: []byte("okk"), Off: base + 1}},
+		{name: "group", raw: "08010c", tag: 1, wire: protowire.StartGroupType, want: Value{S: []byte{8, 1}, Off: base}},
+		{name: "varint_truncated", raw: "ff", tag: 1, wire: protowire.VarintType, wantErr: io.ErrUnexpectedEOF},
+		{name: "varint_overflow", raw: "ffffffffffffffffffff01", tag: 1, wire: protowire.VarintType, wantErr: ErrOverflow},
+		{name: "fixed32_truncated", raw: "0102", tag: 1, wire: protowire.Fixed32Type, wantErr: io.ErrUnexpectedEOF},
+		{name: "fixed64_truncated", raw: "01020304", tag: 1, wire: protowire.Fixed64Type, wantErr: io.ErrUnexpectedEOF},
+		{name: "bytes_truncated", raw: "056f6b", tag: 2, wire: protowire.BytesType, wantErr: io.ErrUnexpectedEOF},
+		{name: "bytes_length_truncated", raw: "80", tag: 2, wire: protowire.BytesType, wantErr: io.ErrUnexpectedEOF},
+		{name: "group_end_mismatch", raw: "08011c", tag: 1, wire: protowire.StartGroupType, wantErr: ErrEndGroup},
+		{name: "group_truncated", raw: "0801", tag: 1, wire: protowire.StartGroupType, wantErr: io.ErrUnexpectedEOF},
+		{name: "end_group", raw: "", tag: 1, wire: protowire.EndGroupType, wantErr: ErrEndGroup},
+		{name: "reserved", raw: "", tag: 1, wire: 6, wantErr: ErrReservedWireType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.raw)
+			dec := NewDecoderAt(raw, base)
+			got, code := dec.ReadValue(tt.tag, tt.wire)
+			if tt.wantErr == nil {
+				if code != 0 || !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("ReadValue() = %v, %d, want %v", got, code, tt.want)
+				}
+				return
+			}
+			if code >= 0 {
+				t.Fatalf("ReadValue() = %v, %d, want error", got, code)
+			}
+			err := dec.ErrorOf(code, tt.tag, tt.wire)
+			var perr *Error
+			if !errors.As(err, &perr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ErrorOf() = %v, want %v", err, tt.wantErr)
+			}
+			// 失败的读取不前移，Offset 是值的起始位置
+			if perr.Offset != base || perr.Tag != tt.tag || perr.Wire != tt.wire {
+				t.Errorf("ErrorOf() = %+v", perr)
+			}
+		})
+	}
+}
@@ -6,74 +6,409 @@ import (
 
 type Encoder struct {
 	buf []byte
+	// open 是 BeginMessage/BeginGroup 开始、尚未 EndMessage 的子消息
+	open []openMessage
+	// fixups 按开始顺序记录最外层子消息结束前各子消息预留的长度前缀，saved 是已结束的子消息整理后将节省的字节数
+	fixups []lenFixup
+	saved  int
+	// sizing 为 true 时只累计将要写出的字节数 size，不写出内容，见 NewSizeEncoder
+	sizing bool
+	size   int
+	// fixed 为 true 时 buf 的容量固定，写满后转为 sizing，见 NewFixedEncoder
+	fixed bool
 }
 
+// openMessage 记录一个未结束的子消息：start 是为长度前缀预留的字节位置，group 时是内容的起始位置，
+// fixup 是它在 fixups 中的下标，saved 是开始时的 Encoder.saved。
+type openMessage struct {
+	start int
+	tag   uint32
+	group bool
+	fixup int
+	saved int
+}
+
+// lenFixup 是 start 处预留的 lenReserve 字节长度前缀，子消息结束后 n 为内容的实际字节数，
+// saving 为改写成 n 的 varint 后节省的字节数。
+type lenFixup struct {
+	start  int
+	n      int
+	saving int
+}
+
+// lenReserve 是为长度前缀预留的字节数，可以表示小于 32GiB 的长度。
+const lenReserve = 5
+
 func (e *Encoder) Len() int {
+	if e.sizing {
+		return e.size
+	}
 	return len(e.buf)
 }
 
 func (e *Encoder) Clear() {
 	e.buf = e.buf[:0]
+	e.open = e.open[:0]
+	e.fixups = e.fixups[:0]
+	e.saved = 0
+	e.size = 0
+	if e.fixed {
+		e.sizing = false
+	}
+}
+
+// Reset 清空 e 并改为写入 buf[:0]，保留嵌套子消息栈的容量，NewFixedEncoder 创建的 e 仍不超出 cap(buf)。
+func (e *Encoder) Reset(buf []byte) {
+	e.Clear()
+	e.buf = buf[:0]
 }
 
+// Truncate 丢弃第 n 字节之后已写出的内容，n 通常是之前 Len 的返回值。
+// 有未结束的子消息时 n 不能早于最近一个未结束子消息的开始位置。
+func (e *Encoder) Truncate(n int) {
+	if e.sizing {
+		// 写满的固定缓冲截断到实际写出的内容以内时恢复写出
+		if e.fixed && n <= len(e.buf) {
+			e.sizing = false
+			e.buf = e.buf[:n]
+			return
+		}
+		e.size = n
+		return
+	}
+	k := len(e.fixups)
+	for k > 0 && e.fixups[k-1].start >= n {
+		k--
+		e.saved -= e.fixups[k].saving
+	}
+	e.fixups = e.fixups[:k]
+	e.buf = e.buf[:n]
+}
+
+// Sizing 返回 e 是否只计算长度，见 NewSizeEncoder。
+func (e *Encoder) Sizing() bool {
+	return e.sizing && !e.fixed
+}
+
+// Overflow 返回 NewFixedEncoder 创建的 e 要写出的内容是否已超出缓冲的容量，此时 Len 为将要写出的字节数。
+// 之后的 Truncate 回到已写出的内容以内时恢复写出。
+func (e *Encoder) Overflow() bool {
+	return e.sizing && e.fixed
+}
+
+// room 检查固定容量的缓冲能否再写入 n 字节，不能时转为只计算长度（size 含这 n 字节）并返回 false。
+func (e *Encoder) room(n int) bool {
+	if len(e.buf)+n <= cap(e.buf) {
+		return true
+	}
+	e.sizing = true
+	e.size = len(e.buf) + n
+	return false
+}
+
+// Bytes 返回已写出的内容，有未结束的子消息时其中的长度前缀尚未确定。
 func (e *Encoder) Bytes() []byte {
 	return e.buf
 }
 
 func (e *Encoder) WriteBytes(s []byte) {
+	if e.sizing {
+		e.size += len(s)
+		return
+	}
+	if e.fixed && !e.room(len(s)) {
+		return
+	}
 	e.buf = append(e.buf, s...)
 }
 
 func (e *Encoder) WriteVarint(v uint64) {
+	if e.sizing {
+		e.size += protowire.SizeVarint(v)
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeVarint(v)) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, v)
 }
 
 func (e *Encoder) WriteZigzag(x int64) {
+	if e.sizing {
+		e.size += protowire.SizeVarint(protowire.EncodeZigZag(x))
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeVarint(protowire.EncodeZigZag(x))) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeZigZag(x))
 }
 
 func (e *Encoder) WriteFixed32(v uint32) {
+	if e.sizing {
+		e.size += protowire.SizeFixed32()
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeFixed32()) {
+		return
+	}
 	e.buf = protowire.AppendFixed32(e.buf, v)
 }
 
 func (e *Encoder) WriteFixed64(v uint64) {
+	if e.sizing {
+		e.size += protowire.SizeFixed64()
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeFixed64()) {
+		return
+	}
 	e.buf = protowire.AppendFixed64(e.buf, v)
 }
 
 func (e *Encoder) EmitVarint(tag uint32, v uint64) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeVarint(v)
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeVarint(v)) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.VarintType))
 	e.buf = protowire.AppendVarint(e.buf, v)
 }
 
 func (e *Encoder) EmitZigzag(tag uint32, x int64) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeVarint(protowire.EncodeZigZag(x))
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeVarint(protowire.EncodeZigZag(x))) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.VarintType))
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeZigZag(x))
 }
 
 func (e *Encoder) EmitFixed32(tag uint32, v uint32) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeFixed32()
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeFixed32()) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.Fixed32Type))
 	e.buf = protowire.AppendFixed32(e.buf, v)
 }
 
 func (e *Encoder) EmitFixed64(tag uint32, v uint64) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeFixed64()
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeFixed64()) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.Fixed64Type))
 	e.buf = protowire.AppendFixed64(e.buf, v)
 }
 
 func (e *Encoder) EmitBytes(tag uint32, s []byte) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeBytes(len(s))
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeBytes(len(s))) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.BytesType))
 	e.buf = protowire.AppendVarint(e.buf, uint64(len(s)))
 	e.buf = append(e.buf, s...)
 }
 
 func (e *Encoder) EmitString(tag uint32, s string) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag)) + protowire.SizeBytes(len(s))
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))+protowire.SizeBytes(len(s))) {
+		return
+	}
 	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.BytesType))
 	e.buf = protowire.AppendVarint(e.buf, uint64(len(s)))
 	e.buf = append(e.buf, s...)
 }
 
+// EmitGroup 写出一个 proto2 group：StartGroup tag、已编码的 group 内容 s 与 EndGroup tag。
+func (e *Encoder) EmitGroup(tag uint32, s []byte) {
+	if e.sizing {
+		e.size += 2*protowire.SizeTag(protowire.Number(tag)) + len(s)
+		return
+	}
+	if e.fixed && !e.room(2*protowire.SizeTag(protowire.Number(tag))+len(s)) {
+		return
+	}
+	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.StartGroupType))
+	e.buf = append(e.buf, s...)
+	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.EndGroupType))
+}
+
+// BeginMessage 开始写出 tag 对应的 length-delimited 子消息，此后写出的内容都属于该子消息，直到对应的 EndMessage。
+// 长度前缀先预留 lenReserve 字节，最外层的子消息结束时一次整理所有长度前缀并前移内容，
+// 嵌套的子消息因此可以直接写在同一个缓冲中，整理的开销与嵌套深度无关。
+// 有未结束的子消息时 Len 包含预留的字节，内容可能比最终结果长。
+// NewFixedEncoder 创建的 Encoder 不能超出缓冲容量，长度前缀先按 1 字节预留，子消息不短于 128 字节时 EndMessage 再后移内容。
+func (e *Encoder) BeginMessage(tag uint32) {
+	e.beginLen(tag)
+}
+
+// BeginDelimited 与 BeginMessage 相同，但不写出 tag，只有 varint 长度前缀（即 writeDelimitedTo 的记录格式），
+// 同样由 EndMessage 结束。
+func (e *Encoder) BeginDelimited() {
+	e.beginLen(0)
+}
+
+// beginLen 开始一个带长度前缀的子消息，tag 为 0 时不写出 tag。
+func (e *Encoder) beginLen(tag uint32) {
+	tagSize := 0
+	if tag != 0 {
+		tagSize = protowire.SizeTag(protowire.Number(tag))
+	}
+	if e.sizing {
+		e.size += tagSize
+		e.open = append(e.open, openMessage{start: e.size, tag: tag})
+		e.size++
+		return
+	}
+	if e.fixed {
+		if e.room(tagSize + 1) {
+			if tag != 0 {
+				e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.BytesType))
+			}
+			e.open = append(e.open, openMessage{start: len(e.buf), tag: tag})
+			e.buf = append(e.buf, 0)
+		} else {
+			e.open = append(e.open, openMessage{start: e.size - 1, tag: tag})
+		}
+		return
+	}
+	if tag != 0 {
+		e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.BytesType))
+	}
+	e.open = append(e.open, openMessage{start: len(e.buf), tag: tag, fixup: len(e.fixups), saved: e.saved})
+	e.fixups = append(e.fixups, lenFixup{start: len(e.buf)})
+	e.buf = append(e.buf, make([]byte, lenReserve)...)
+}
+
+// BeginGroup 开始写出 tag 对应的 proto2 group，由 EndMessage 写出 EndGroup tag。
+func (e *Encoder) BeginGroup(tag uint32) {
+	if e.sizing {
+		e.size += protowire.SizeTag(protowire.Number(tag))
+		e.open = append(e.open, openMessage{start: e.size, tag: tag, group: true})
+		return
+	}
+	if e.fixed && !e.room(protowire.SizeTag(protowire.Number(tag))) {
+		e.open = append(e.open, openMessage{start: e.size, tag: tag, group: true})
+		return
+	}
+	e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(tag), protowire.StartGroupType))
+	e.open = append(e.open, openMessage{start: len(e.buf), tag: tag, group: true, saved: e.saved})
+}
+
+// EndMessage 结束最近一个未结束的 BeginMessage/BeginGroup，返回子消息内容的字节数。
+func (e *Encoder) EndMessage() int {
+	m := e.open[len(e.open)-1]
+	e.open = e.open[:len(e.open)-1]
+	if e.sizing {
+		if m.group {
+			n := e.size - m.start
+			e.size += protowire.SizeTag(protowire.Number(m.tag))
+			return n
+		}
+		n := e.size - m.start - 1
+		e.size += protowire.SizeVarint(uint64(n)) - 1
+		return n
+	}
+	if e.fixed {
+		return e.endFixed(m)
+	}
+	// 内容中已结束的子消息整理后节省的字节不计入长度
+	var n int
+	if m.group {
+		n = len(e.buf) - m.start - (e.saved - m.saved)
+		e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(m.tag), protowire.EndGroupType))
+	} else {
+		n = len(e.buf) - m.start - lenReserve - (e.saved - m.saved)
+		f := &e.fixups[m.fixup]
+		f.n = n
+		f.saving = lenReserve - protowire.SizeVarint(uint64(n))
+		e.saved += f.saving
+	}
+	if len(e.open) == 0 && len(e.fixups) != 0 {
+		e.compact()
+	}
+	return n
+}
+
+// endFixed 是固定容量缓冲的 EndMessage：长度前缀预留了 1 字节，不够时在缓冲容量内后移内容。
+func (e *Encoder) endFixed(m openMessage) int {
+	if m.group {
+		n := len(e.buf) - m.start
+		if e.room(protowire.SizeTag(protowire.Number(m.tag))) {
+			e.buf = protowire.AppendVarint(e.buf, protowire.EncodeTag(protowire.Number(m.tag), protowire.EndGroupType))
+		}
+		return n
+	}
+	n := len(e.buf) - m.start - 1
+	if n < 0x80 {
+		e.buf[m.start] = byte(n)
+		return n
+	}
+	k := protowire.SizeVarint(uint64(n))
+	if !e.room(k - 1) {
+		return n
+	}
+	e.buf = e.buf[:len(e.buf)+k-1]
+	copy(e.buf[m.start+k:], e.buf[m.start+1:m.start+1+n])
+	protowire.AppendVarint(e.buf[m.start:m.start], uint64(n))
+	return n
+}
+
+// compact 把 fixups 中预留的长度前缀改写为实际长度并前移之后的内容，在最外层的子消息结束时调用。
+func (e *Encoder) compact() {
+	w := e.fixups[0].start
+	r := w
+	for _, f := range e.fixups {
+		w += copy(e.buf[w:], e.buf[r:f.start])
+		w = len(protowire.AppendVarint(e.buf[:w], uint64(f.n)))
+		r = f.start + lenReserve
+	}
+	w += copy(e.buf[w:], e.buf[r:])
+	e.buf = e.buf[:w]
+	e.fixups = e.fixups[:0]
+	e.saved = 0
+}
+
 func NewEncoder(buf []byte) *Encoder {
 	return &Encoder{
 		buf: buf,
 	}
 }
+
+// NewFixedEncoder 返回一个写入 buf[:0] 且不会超出 cap(buf) 的 Encoder，写出过程中不分配内存。
+// 内容超出容量后不再写出，只累计长度：Overflow 返回 true，Len 为将要写出的字节数。
+func NewFixedEncoder(buf []byte) *Encoder {
+	return &Encoder{
+		buf:   buf[:0],
+		fixed: true,
+	}
+}
+
+// NewSizeEncoder 返回一个只计算长度的 Encoder：写入方法只累计字节数，Len 返回按同样调用序列编码的准确长度，
+// Bytes 始终为空。BeginMessage/EndMessage 与 Truncate 的长度计算与正常编码一致。
+func NewSizeEncoder() *Encoder {
+	return &Encoder{
+		sizing: true,
+	}
+}
@@ -3,6 +3,8 @@ package proto
 import (
 	"bytes"
 	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func TestEncode(t *testing.T) {
@@ -16,3 +18,223 @@ func TestEncode(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestEncode_group(t *testing.T) {
+	e := NewEncoder(nil)
+	e.EmitGroup(7, []byte{8, 1})
+	e.EmitGroup(20, nil)
+	want := []byte{59, 8, 1, 60, 163, 1, 164, 1}
+	if !bytes.Equal(e.Bytes(), want) {
+		t.Fatal(e.Bytes())
+	}
+}
+
+func TestEncoder_BeginMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "one_byte_len", size: 127},
+		{name: "two_byte_len", size: 128},
+		{name: "three_byte_len", size: 1 << 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := bytes.Repeat([]byte{'x'}, tt.size)
+
+			var inner, want Encoder
+			inner.EmitBytes(2, payload)
+			inner.EmitGroup(3, []byte{8, 1})
+			want.EmitVarint(1, 1)
+			want.EmitBytes(4, inner.Bytes())
+			want.EmitVarint(5, 1)
+
+			var got Encoder
+			got.EmitVarint(1, 1)
+			got.BeginMessage(4)
+			got.EmitBytes(2, payload)
+			got.BeginGroup(3)
+			got.EmitVarint(1, 1)
+			if n := got.EndMessage(); n != 2 {
+				t.Errorf("EndMessage() of group = %d, want 2", n)
+			}
+			if n := got.EndMessage(); n != inner.Len() {
+				t.Errorf("EndMessage() = %d, want %d", n, inner.Len())
+			}
+			got.EmitVarint(5, 1)
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("got %x, want %x", got.Bytes(), want.Bytes())
+			}
+		})
+	}
+}
+
+func TestEncoder_Truncate(t *testing.T) {
+	var e Encoder
+	e.EmitVarint(1, 1)
+	mark := e.Len()
+	e.BeginMessage(2)
+	if n := e.EndMessage(); n == 0 {
+		e.Truncate(mark)
+	}
+	if !bytes.Equal(e.Bytes(), []byte{8, 1}) {
+		t.Fatal(e.Bytes())
+	}
+}
+
+func TestEncoder_nested(t *testing.T) {
+	// 每层都含有超过 128 字节的内容，外层长度前缀的字节数各不相同
+	const depth = 40
+	payload := bytes.Repeat([]byte{'x'}, 200)
+	want := []byte{}
+	for i := 0; i < depth; i++ {
+		var e Encoder
+		e.EmitBytes(1, payload)
+		if i%2 == 0 {
+			e.EmitGroup(3, []byte{8, 1})
+		}
+		if i > 0 {
+			e.EmitBytes(2, want)
+		}
+		want = e.Bytes()
+	}
+
+	var got Encoder
+	var build func(i int)
+	build = func(i int) {
+		got.EmitBytes(1, payload)
+		if i%2 == 0 {
+			got.BeginGroup(3)
+			got.EmitVarint(1, 1)
+			got.EndMessage()
+		}
+		mark := got.Len()
+		got.BeginMessage(2)
+		if i > 0 {
+			build(i - 1)
+		}
+		// 撤销一个已结束的空子消息，不影响外层的长度
+		inner := got.Len()
+		got.BeginMessage(9)
+		got.EndMessage()
+		got.Truncate(inner)
+		if n := got.EndMessage(); i == 0 && n == 0 {
+			got.Truncate(mark)
+		}
+	}
+	build(depth - 1)
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("got %d bytes, want %d", got.Len(), len(want))
+	}
+}
+
+func TestNewSizeEncoder(t *testing.T) {
+	write := func(e *Encoder, size int) {
+		e.EmitVarint(1, 300)
+		e.EmitZigzag(2, -1)
+		e.EmitFixed32(3, 1)
+		e.EmitFixed64(4, 1)
+		e.EmitString(5, "abc")
+		e.EmitGroup(6, []byte{8, 1})
+		e.BeginMessage(20)
+		e.EmitBytes(2, bytes.Repeat([]byte{'x'}, size))
+		e.BeginGroup(3)
+		e.WriteVarint(8)
+		e.WriteZigzag(-300)
+		e.WriteFixed32(1)
+		e.WriteFixed64(1)
+		e.WriteBytes([]byte{1, 2})
+		e.EndMessage()
+		mark := e.Len()
+		e.BeginMessage(4)
+		if e.EndMessage() == 0 {
+			e.Truncate(mark)
+		}
+		e.EndMessage()
+	}
+	for _, size := range []int{0, 100, 200, 1 << 14} {
+		var want Encoder
+		write(&want, size)
+		got := NewSizeEncoder()
+		write(got, size)
+		if got.Len() != want.Len() || len(got.Bytes()) != 0 {
+			t.Errorf("size %d: Len() = %d, want %d", size, got.Len(), want.Len())
+		}
+	}
+}
+
+func TestEncoder_BeginDelimited(t *testing.T) {
+	payload := bytes.Repeat([]byte{'x'}, 200)
+	var sub Encoder
+	sub.EmitVarint(1, 1)
+	sub.EmitBytes(2, payload)
+	want := protowire.AppendVarint([]byte{8, 1}, uint64(sub.Len()))
+	want = append(want, sub.Bytes()...)
+
+	write := func(e *Encoder) {
+		e.EmitVarint(1, 1)
+		e.BeginDelimited()
+		e.EmitVarint(1, 1)
+		e.BeginMessage(2)
+		e.WriteBytes(payload)
+		e.EndMessage()
+		e.EndMessage()
+	}
+	var got Encoder
+	write(&got)
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("got %x, want %x", got.Bytes(), want)
+	}
+	fixed := NewFixedEncoder(make([]byte, len(want)))
+	write(fixed)
+	if fixed.Overflow() || !bytes.Equal(fixed.Bytes(), want) {
+		t.Fatalf("fixed: got %x, want %x", fixed.Bytes(), want)
+	}
+	size := NewSizeEncoder()
+	write(size)
+	if size.Len() != len(want) {
+		t.Fatalf("sizing: Len() = %d, want %d", size.Len(), len(want))
+	}
+}
+
+func TestNewFixedEncoder(t *testing.T) {
+	payload := bytes.Repeat([]byte{'x'}, 200)
+	write := func(e *Encoder) {
+		e.EmitVarint(1, 1)
+		e.BeginMessage(2)
+		e.EmitBytes(3, payload)
+		e.BeginGroup(4)
+		e.EmitVarint(1, 1)
+		e.EndMessage()
+		e.EndMessage()
+		// 空子消息先写出再截断，中间结果可能短暂超出容量
+		mark := e.Len()
+		e.BeginMessage(5)
+		if e.EndMessage() == 0 {
+			e.Truncate(mark)
+		}
+	}
+	var want Encoder
+	write(&want)
+
+	buf := make([]byte, want.Len())
+	e := NewFixedEncoder(buf)
+	write(e)
+	if e.Overflow() || !bytes.Equal(e.Bytes(), want.Bytes()) || &e.Bytes()[0] != &buf[0] {
+		t.Fatalf("got %x, want %x", e.Bytes(), want.Bytes())
+	}
+
+	// 容量不足时只计算长度，不会换用新的缓冲
+	for _, size := range []int{0, 3, 100, want.Len() - 1} {
+		e.Reset(buf[:0:size])
+		write(e)
+		if !e.Overflow() || e.Len() != want.Len() {
+			t.Errorf("cap %d: Overflow() = %v, Len() = %d, want %d", size, e.Overflow(), e.Len(), want.Len())
+		}
+	}
+	e.Clear()
+	if e.Overflow() || e.Len() != 0 {
+		t.Fatal("Clear did not reset the encoder")
+	}
+}
@@ -0,0 +1,64 @@
+package proto
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Decoder 各方法的 int 错误码，与 protowire 内部错误码一致。
+const (
+	errCodeTruncated   = -1
+	errCodeFieldNumber = -2
+	errCodeOverflow    = -3
+	errCodeReserved    = -4
+	errCodeEndGroup    = -5
+)
+
+var (
+	ErrInvalidFieldNumber = errors.New("invalid field number")
+	ErrOverflow           = errors.New("variable length integer overflow")
+	ErrReservedWireType   = errors.New("reserved wire type")
+	ErrEndGroup           = errors.New("mismatching end group marker")
+	ErrMalformed          = errors.New("malformed wire data")
+)
+
+// codeError 把 int 错误码转换为对应的错误，输入被截断时为 io.ErrUnexpectedEOF。
+func codeError(code int) error {
+	switch code {
+	case errCodeTruncated:
+		return io.ErrUnexpectedEOF
+	case errCodeFieldNumber:
+		return ErrInvalidFieldNumber
+	case errCodeOverflow:
+		return ErrOverflow
+	case errCodeReserved:
+		return ErrReservedWireType
+	case errCodeEndGroup:
+		return ErrEndGroup
+	}
+	return ErrMalformed
+}
+
+// Error 是解码 wire 数据失败时返回的错误。Offset 为失败的读取在输入中的起始偏移，
+// Tag 与 Wire 为正在读取的字段（读取 tag 本身失败时为 0），Err 为具体原因，可用 errors.Is 判断。
+type Error struct {
+	Offset int64
+	Tag    uint32
+	Wire   protowire.Type
+	Err    error
+}
+
+func (e *Error) Error() string {
+	s := "offset " + strconv.FormatInt(e.Offset, 10)
+	if e.Tag != 0 {
+		s += " field " + strconv.FormatUint(uint64(e.Tag), 10) + " (wire type " + strconv.Itoa(int(e.Wire)) + ")"
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,30 @@
+package proto
+
+import (
+	"sync"
+
+	"github.com/vizee/jsonpb/internal/bufpool"
+)
+
+// encoderPools 按缓冲容量分级，级别 i 中 Encoder 的容量不小于 256<<i
+var encoderPools [bufpool.NumClasses]sync.Pool
+
+// AcquireEncoder 从池中取得一个容量至少为 size 的空 Encoder，用完后调用 Release 归还。
+func AcquireEncoder(size int) *Encoder {
+	class, c := bufpool.AcquireClass(size)
+	if class >= 0 {
+		if e, _ := encoderPools[class].Get().(*Encoder); e != nil {
+			return e
+		}
+	}
+	return &Encoder{buf: make([]byte, 0, c)}
+}
+
+// Release 清空 e 并放回池中，之后不能再使用 e 及 Bytes 返回过的切片。
+// 容量超过 1MiB 的缓冲不放回池中，避免偶发的大消息长期占用内存。
+func (e *Encoder) Release() {
+	e.Clear()
+	if class := bufpool.ReleaseClass(cap(e.buf)); class >= 0 {
+		encoderPools[class].Put(e)
+	}
+}
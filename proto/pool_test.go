@@ -0,0 +1,18 @@
+package proto
+
+import "testing"
+
+func TestAcquireEncoder(t *testing.T) {
+	for _, size := range []int{0, 300, 5 << 20} {
+		e := AcquireEncoder(size)
+		if e.Len() != 0 || cap(e.buf) < size {
+			t.Fatalf("AcquireEncoder(%d): len %d cap %d", size, e.Len(), cap(e.buf))
+		}
+		e.BeginMessage(1)
+		e.EmitVarint(1, 1)
+		e.Release()
+		if e.Len() != 0 || len(e.open) != 0 {
+			t.Fatal("Release did not clear the encoder")
+		}
+	}
+}
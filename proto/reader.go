@@ -0,0 +1,287 @@
+package proto
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const defaultReaderBufferSize = 4096
+
+const (
+	binaryMaxVarintLen = 10
+	maxInt             = int(^uint(0) >> 1)
+)
+
+var ErrTooLarge = errors.New("length-delimited value exceeds max length")
+
+// ReaderDecoder 从 io.Reader 增量读取 protobuf wire 数据。
+// 标量按需读取；length-delimited 值整体读入内部窗口，只在下一次读取之前有效，
+// 因此内存占用取决于单个 length-delimited 值的大小而不是整个输入。
+// 解码失败时各方法返回 *Error，输入被截断时其 Err 为 io.ErrUnexpectedEOF；底层 io.Reader 的读取错误原样返回。
+type ReaderDecoder struct {
+	r   io.Reader
+	buf []byte
+	p   int
+	off int64 // buf[0] 在输入中的偏移
+	err error
+	// maxLen 是读入窗口的单个值的最大字节数，0 表示不限制
+	maxLen int
+}
+
+// NewReaderDecoder 创建一个从 r 读取的 ReaderDecoder，size 为初始窗口大小，size <= 0 时使用 4096。
+func NewReaderDecoder(r io.Reader, size int) *ReaderDecoder {
+	if size <= 0 {
+		size = defaultReaderBufferSize
+	}
+	return &ReaderDecoder{
+		r:   r,
+		buf: make([]byte, 0, size),
+	}
+}
+
+func (d *ReaderDecoder) Reset(r io.Reader) {
+	d.r = r
+	d.buf = d.buf[:0]
+	d.p = 0
+	d.off = 0
+	d.err = nil
+}
+
+// SetMaxLen 限制 ReadBytes、ReadGroup 与 ReadValue 读入窗口的单个值的最大字节数，n <= 0 表示不限制（默认）。
+// length-delimited 值声明的长度超出时在读取内容之前返回 Err 为 ErrTooLarge 的 *Error，
+// 超大的值因此不会被整体读入内存；SkipBytes 不读入整个值，不受影响。
+func (d *ReaderDecoder) SetMaxLen(n int) {
+	d.maxLen = max(n, 0)
+}
+
+// Offset 返回下一个未读字节在输入中的偏移。
+func (d *ReaderDecoder) Offset() int64 {
+	return d.off + int64(d.p)
+}
+
+// fill 尽量让窗口中至少有 n 个未读字节，返回实际可用的字节数。
+// 窗口按需增长，不会按 n 预先分配，所以伪造的超大长度不会导致超大分配。
+func (d *ReaderDecoder) fill(n int) int {
+	empty := 0
+	for len(d.buf)-d.p < n && d.err == nil {
+		if d.p > 0 {
+			m := copy(d.buf, d.buf[d.p:])
+			d.buf = d.buf[:m]
+			d.off += int64(d.p)
+			d.p = 0
+		}
+		if len(d.buf) == cap(d.buf) {
+			newbuf := make([]byte, len(d.buf), 2*cap(d.buf))
+			copy(newbuf, d.buf)
+			d.buf = newbuf
+		}
+		m, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+m]
+		if err != nil {
+			d.err = err
+		} else if m == 0 {
+			empty++
+			if empty >= 100 {
+				d.err = io.ErrNoProgress
+			}
+		}
+	}
+	return len(d.buf) - d.p
+}
+
+// shortErr 返回可用数据不足时的错误：输入被截断时为 Err 是 io.ErrUnexpectedEOF 的 *Error，其它读取错误原样返回。
+func (d *ReaderDecoder) shortErr() error {
+	if d.err == nil || d.err == io.EOF {
+		return &Error{Offset: d.Offset(), Err: io.ErrUnexpectedEOF}
+	}
+	return d.err
+}
+
+// codeErr 把 protowire 的错误码转换为当前位置的 *Error。
+func (d *ReaderDecoder) codeErr(code int) error {
+	return &Error{Offset: d.Offset(), Err: codeError(code)}
+}
+
+// withField 为 *Error 补充正在读取的字段。
+func withField(err error, tag uint32, wire protowire.Type) error {
+	if e, ok := err.(*Error); ok && e.Tag == 0 {
+		e.Tag, e.Wire = tag, wire
+	}
+	return err
+}
+
+func (d *ReaderDecoder) EOF() bool {
+	return d.fill(1) == 0
+}
+
+// Err 返回读取时遇到的错误，读到输入末尾不算错误。
+func (d *ReaderDecoder) Err() error {
+	if d.err == io.EOF {
+		return nil
+	}
+	return d.err
+}
+
+func (d *ReaderDecoder) ReadVarint() (uint64, error) {
+	d.fill(binaryMaxVarintLen)
+	v, n := protowire.ConsumeVarint(d.buf[d.p:])
+	if n < 0 {
+		if n == errCodeTruncated {
+			return 0, d.shortErr()
+		}
+		return 0, d.codeErr(n)
+	}
+	d.p += n
+	return v, nil
+}
+
+func (d *ReaderDecoder) ReadZigzag() (int64, error) {
+	v, err := d.ReadVarint()
+	return protowire.DecodeZigZag(v), err
+}
+
+func (d *ReaderDecoder) ReadTag() (uint32, protowire.Type, error) {
+	v, err := d.ReadVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	tag, wire := protowire.DecodeTag(v)
+	return uint32(tag), wire, nil
+}
+
+func (d *ReaderDecoder) ReadFixed32() (uint32, error) {
+	if d.fill(4) < 4 {
+		return 0, d.shortErr()
+	}
+	v, n := protowire.ConsumeFixed32(d.buf[d.p:])
+	d.p += n
+	return v, nil
+}
+
+func (d *ReaderDecoder) ReadFixed64() (uint64, error) {
+	if d.fill(8) < 8 {
+		return 0, d.shortErr()
+	}
+	v, n := protowire.ConsumeFixed64(d.buf[d.p:])
+	d.p += n
+	return v, nil
+}
+
+// ReadBytes 读取一个 length-delimited 值，返回的切片只在下一次读取之前有效。
+func (d *ReaderDecoder) ReadBytes() ([]byte, error) {
+	m, err := d.ReadVarint()
+	if err != nil {
+		return nil, err
+	}
+	if m > uint64(maxInt) {
+		return nil, d.codeErr(errCodeOverflow)
+	}
+	if d.maxLen > 0 && m > uint64(d.maxLen) {
+		return nil, &Error{Offset: d.Offset(), Err: ErrTooLarge}
+	}
+	n := int(m)
+	if d.fill(n) < n {
+		return nil, d.shortErr()
+	}
+	v := d.buf[d.p : d.p+n]
+	d.p += n
+	return v, nil
+}
+
+// ReadGroup 读取 StartGroup tag 之后的 group 内容（不含 EndGroup tag），
+// 整个 group 需要读入窗口，返回的切片只在下一次读取之前有效。
+func (d *ReaderDecoder) ReadGroup(tag uint32) ([]byte, error) {
+	want := defaultReaderBufferSize
+	for {
+		if d.maxLen > 0 && want > d.maxLen {
+			// group 没有长度前缀，读入 maxLen 字节后仍未结束即超出
+			want = d.maxLen + 1
+		}
+		avail := d.fill(want)
+		v, n := protowire.ConsumeGroup(protowire.Number(tag), d.buf[d.p:])
+		if n >= 0 {
+			d.p += n
+			return v, nil
+		}
+		if n != errCodeTruncated {
+			return nil, withField(d.codeErr(n), tag, protowire.StartGroupType)
+		}
+		if avail < want {
+			return nil, withField(d.shortErr(), tag, protowire.StartGroupType)
+		}
+		if d.maxLen > 0 && avail > d.maxLen {
+			return nil, withField(&Error{Offset: d.Offset(), Err: ErrTooLarge}, tag, protowire.StartGroupType)
+		}
+		want = 2 * avail
+	}
+}
+
+// SkipField 跳过 tag 之后类型为 wire 的字段值，bytes 不要求整个值能放入窗口，group 需要整体读入窗口。
+func (d *ReaderDecoder) SkipField(tag uint32, wire protowire.Type) error {
+	var err error
+	switch wire {
+	case protowire.VarintType:
+		_, err = d.ReadVarint()
+	case protowire.Fixed32Type:
+		_, err = d.ReadFixed32()
+	case protowire.Fixed64Type:
+		_, err = d.ReadFixed64()
+	case protowire.BytesType:
+		err = d.SkipBytes()
+	case protowire.StartGroupType:
+		_, err = d.ReadGroup(tag)
+	case protowire.EndGroupType:
+		err = d.codeErr(errCodeEndGroup)
+	default:
+		err = d.codeErr(errCodeReserved)
+	}
+	return withField(err, tag, wire)
+}
+
+// ReadValue 读取 tag 之后类型为 wire 的字段值，与 Decoder.ReadValue 相同，但 S 只在下一次读取之前有效。
+func (d *ReaderDecoder) ReadValue(tag uint32, wire protowire.Type) (v Value, err error) {
+	switch wire {
+	case protowire.VarintType:
+		v.X, err = d.ReadVarint()
+	case protowire.Fixed32Type:
+		var t uint32
+		t, err = d.ReadFixed32()
+		v.X = uint64(t)
+	case protowire.Fixed64Type:
+		v.X, err = d.ReadFixed64()
+	case protowire.BytesType:
+		v.S, err = d.ReadBytes()
+		v.Off = d.Offset() - int64(len(v.S))
+	case protowire.StartGroupType:
+		v.Off = d.Offset()
+		v.S, err = d.ReadGroup(tag)
+	case protowire.EndGroupType:
+		err = d.codeErr(errCodeEndGroup)
+	default:
+		err = d.codeErr(errCodeReserved)
+	}
+	return v, withField(err, tag, wire)
+}
+
+// SkipBytes 跳过一个 length-delimited 值，不要求整个值能放入窗口。
+func (d *ReaderDecoder) SkipBytes() error {
+	m, err := d.ReadVarint()
+	if err != nil {
+		return err
+	}
+	for m > 0 {
+		avail := d.fill(1)
+		if avail == 0 {
+			return d.shortErr()
+		}
+		k := uint64(avail)
+		if k > m {
+			k = m
+		}
+		d.p += int(k)
+		m -= k
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package proto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestReaderDecoder(t *testing.T) {
+	raw := []byte{8, 233, 1, 18, 4, 116, 101, 115, 116, 29, 219, 3, 0, 0, 32, 209, 3, 42, 2, 1, 2, 49, 1, 0, 0, 0, 0, 0, 0, 0}
+	dec := NewReaderDecoder(iotest.OneByteReader(bytes.NewReader(raw)), 1)
+	readTag := func() (uint32, protowire.Type) {
+		a, b, err := dec.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a, b
+	}
+	assert2(t, readTag, 1, protowire.VarintType)
+	assert2(t, dec.ReadVarint, 233, nil)
+	assert2(t, readTag, 2, protowire.BytesType)
+	data, err := dec.ReadBytes()
+	if err != nil || string(data) != "test" {
+		t.Fatal("ReadBytes", string(data), err)
+	}
+	assert2(t, readTag, 3, protowire.Fixed32Type)
+	assert2(t, dec.ReadFixed32, 987, nil)
+	assert2(t, readTag, 4, protowire.VarintType)
+	assert2(t, dec.ReadZigzag, -233, nil)
+	assert2(t, readTag, 5, protowire.BytesType)
+	if err := dec.SkipBytes(); err != nil {
+		t.Fatal("SkipBytes", err)
+	}
+	assert2(t, readTag, 6, protowire.Fixed64Type)
+	assert2(t, dec.ReadFixed64, 1, nil)
+	if !dec.EOF() || dec.Err() != nil || dec.Offset() != int64(len(raw)) {
+		t.Fatal("EOF", dec.Err(), dec.Offset())
+	}
+}
+
+func TestReaderDecoder_truncated(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		read func(d *ReaderDecoder) error
+	}{
+		{name: "varint", raw: []byte{0x80}, read: func(d *ReaderDecoder) error { _, err := d.ReadVarint(); return err }},
+		{name: "fixed32", raw: []byte{1, 2, 3}, read: func(d *ReaderDecoder) error { _, err := d.ReadFixed32(); return err }},
+		{name: "fixed64", raw: []byte{1, 2, 3, 4, 5, 6, 7}, read: func(d *ReaderDecoder) error { _, err := d.ReadFixed64(); return err }},
+		{name: "bytes", raw: []byte{4, 1, 2}, read: func(d *ReaderDecoder) error { _, err := d.ReadBytes(); return err }},
+		{name: "huge_bytes", raw: []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 1}, read: func(d *ReaderDecoder) error { _, err := d.ReadBytes(); return err }},
+		{name: "skip_bytes", raw: []byte{4, 1, 2}, read: func(d *ReaderDecoder) error { return d.SkipBytes() }},
+		{name: "group", raw: []byte{8, 1, 18, 1}, read: func(d *ReaderDecoder) error { _, err := d.ReadGroup(1); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewReaderDecoder(iotest.OneByteReader(bytes.NewReader(tt.raw)), 1)
+			if err := tt.read(d); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReaderDecoder_group(t *testing.T) {
+	body := bytes.Repeat([]byte{8, 1}, 3000)
+	raw := append([]byte{59}, body...)
+	raw = append(raw, 60, 8, 2)
+	dec := NewReaderDecoder(iotest.OneByteReader(bytes.NewReader(raw)), 1)
+	tag, wire, err := dec.ReadTag()
+	if err != nil || tag != 7 || wire != protowire.StartGroupType {
+		t.Fatal("ReadTag", tag, wire, err)
+	}
+	data, err := dec.ReadGroup(tag)
+	if err != nil || !bytes.Equal(data, body) {
+		t.Fatal("ReadGroup", len(data), err)
+	}
+	assert2(t, func() (uint32, error) { tag, _, err := dec.ReadTag(); return tag, err }, 1, nil)
+	assert2(t, dec.ReadVarint, 2, nil)
+
+	dec = NewReaderDecoder(bytes.NewReader([]byte{8, 1, 28}), 0)
+	if _, err := dec.ReadGroup(1); !errors.Is(err, ErrEndGroup) {
+		t.Fatal("ReadGroup mismatch", err)
+	}
+}
+
+func TestReaderDecoder_SkipField(t *testing.T) {
+	raw := []byte{8, 233, 1, 17, 1, 2, 3, 4, 5, 6, 7, 8, 26, 2, 1, 2, 37, 1, 2, 3, 4, 43, 51, 8, 1, 52, 58, 1, 0, 44, 8, 5}
+	dec := NewReaderDecoder(iotest.OneByteReader(bytes.NewReader(raw)), 1)
+	for i := 0; i < 5; i++ {
+		tag, wire, err := dec.ReadTag()
+		if err != nil {
+			t.Fatal("ReadTag", err)
+		}
+		if err := dec.SkipField(tag, wire); err != nil {
+			t.Fatal("SkipField", tag, wire, err)
+		}
+	}
+	assert2(t, func() (uint32, error) { tag, _, err := dec.ReadTag(); return tag, err }, 1, nil)
+	assert2(t, dec.ReadVarint, 5, nil)
+
+	if err := NewReaderDecoder(bytes.NewReader(nil), 0).SkipField(1, protowire.EndGroupType); err == nil {
+		t.Fatal("SkipField EndGroupType")
+	}
+}
+
+func TestReaderDecoder_ReadValue(t *testing.T) {
+	dec := NewReaderDecoder(bytes.NewReader([]byte{8, 1, 18, 3, 'a', 'b', 'c', 26, 5, 'x'}), 0)
+	for _, want := range []Value{{X: 1}, {S: []byte("abc")}} {
+		tag, wire, err := dec.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := dec.ReadValue(tag, wire)
+		if err != nil || v.X != want.X || !bytes.Equal(v.S, want.S) {
+			t.Fatal("ReadValue", v, err)
+		}
+	}
+	tag, wire, _ := dec.ReadTag()
+	_, err := dec.ReadValue(tag, wire)
+	var perr *Error
+	if !errors.As(err, &perr) || !errors.Is(err, io.ErrUnexpectedEOF) || perr.Tag != 3 || perr.Wire != protowire.BytesType {
+		t.Fatalf("ReadValue() error = %v", err)
+	}
+	if _, err := dec.ReadValue(1, protowire.EndGroupType); !errors.Is(err, ErrEndGroup) {
+		t.Fatalf("ReadValue() error = %v", err)
+	}
+}
+
+func TestReaderDecoder_SetMaxLen(t *testing.T) {
+	// 声明 8MiB 的 bytes 值，读取内容之前即返回 ErrTooLarge
+	raw := protowire.AppendVarint([]byte{10}, 8<<20)
+	r := &countReader{r: io.MultiReader(bytes.NewReader(raw), bytes.NewReader(make([]byte, 8<<20)))}
+	dec := NewReaderDecoder(r, 0)
+	dec.SetMaxLen(1024)
+	tag, wire, err := dec.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dec.ReadValue(tag, wire)
+	var perr *Error
+	if !errors.As(err, &perr) || !errors.Is(err, ErrTooLarge) || perr.Tag != 1 || perr.Offset != 5 {
+		t.Fatalf("ReadValue() error = %v", err)
+	}
+	if r.n > 2*defaultReaderBufferSize {
+		t.Fatalf("read %d bytes", r.n)
+	}
+
+	// group 没有长度前缀，按已读入的内容判断
+	group := append(append([]byte{11}, bytes.Repeat([]byte{8, 1}, 3000)...), 12)
+	dec = NewReaderDecoder(bytes.NewReader(group), 0)
+	dec.SetMaxLen(1024)
+	tag, wire, _ = dec.ReadTag()
+	if _, err := dec.ReadValue(tag, wire); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("ReadValue() error = %v", err)
+	}
+
+	dec = NewReaderDecoder(bytes.NewReader([]byte{3, 'a', 'b', 'c'}), 0)
+	dec.SetMaxLen(3)
+	if v, err := dec.ReadBytes(); err != nil || string(v) != "abc" {
+		t.Fatal("ReadBytes", string(v), err)
+	}
+}
+
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
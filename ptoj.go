@@ -14,33 +14,10 @@ var (
 	ErrInvalidWireType = errors.New("invalid wire type")
 )
 
-type protoValue struct {
-	x uint64
-	s []byte
-}
-
 // fieldScan 记录某个字段在 wire 流中的一次出现。
 type fieldScan struct {
 	wire protowire.Type
-	val  protoValue
-}
-
-func readProtoValue(p *proto.Decoder, wire protowire.Type) (val protoValue, e int) {
-	switch wire {
-	case protowire.VarintType:
-		val.x, e = p.ReadVarint()
-	case protowire.Fixed32Type:
-		var t uint32
-		t, e = p.ReadFixed32()
-		val.x = uint64(t)
-	case protowire.Fixed64Type:
-		val.x, e = p.ReadFixed64()
-	case protowire.BytesType:
-		val.s, e = p.ReadBytes()
-	default:
-		e = -100
-	}
-	return
+	val  proto.Value
 }
 
 var wireTypeOfKind = [...]protowire.Type{
@@ -61,11 +38,16 @@ var wireTypeOfKind = [...]protowire.Type{
 	// BytesKind:    protowire.BytesType,
 	// MapKind:      protowire.BytesType,
 	// MessageKind:  protowire.BytesType,
+	// GroupKind:    protowire.StartGroupType,
 }
 
 func getFieldWireType(kind Kind, repeated bool) protowire.Type {
 	// 如果字段设置 repeated，那么 packed/string/bytes/message 的 wire 一定是 BytesType
 	// （但 repeated 标量也允许 unpacked 编码，见 acceptFieldWire）。
+	// group 无论是否 repeated 都是 StartGroupType。
+	if kind == GroupKind {
+		return protowire.StartGroupType
+	}
 	if !repeated && int(kind) < len(wireTypeOfKind) {
 		return wireTypeOfKind[kind]
 	}
@@ -76,7 +58,7 @@ func getFieldWireType(kind Kind, repeated bool) protowire.Type {
 // 重复标量字段允许 packed (BytesType) 或 unpacked (标量自身 wire 类型) 两种编码，
 // 这是 proto3 规范要求的：解析器必须同时接受两种编码。
 func acceptFieldWire(field *Field, wire protowire.Type) bool {
-	if field.Repeated {
+	if field.Repeated && field.Kind != GroupKind {
 		if wire == protowire.BytesType {
 			return true
 		}
@@ -106,36 +88,47 @@ var defaultValues = [...]string{
 	BytesKind:    `""`,
 	MapKind:      `{}`,
 	MessageKind:  `{}`,
+	GroupKind:    `{}`,
 }
 
-func writeDefaultValue(j *JsonBuilder, repeated bool, kind Kind) {
-	if repeated {
+// writeDefaultValue 输出缺失字段的默认值，非重复字段优先使用 field.Default。
+func writeDefaultValue(j *JsonBuilder, field *Field) {
+	switch {
+	case field.Repeated:
 		j.AppendString("[]")
-	} else {
-		j.AppendString(defaultValues[kind])
+	case field.Default != "":
+		j.AppendString(field.Default)
+	default:
+		j.AppendString(defaultValues[field.Kind])
 	}
 }
 
-// transProtoMapEntry 把一条 map entry 的字节解码并追加 "key":value 到 j
+// transProtoMapEntry 把一条 map entry 的值 s 解码并追加 "key":value 到 j
 // （不含外层大括号与元素间逗号）。
-func transProtoMapEntry(j *JsonBuilder, entry *Message, s []byte) error {
+func (st *ptojState) transProtoMapEntry(j *JsonBuilder, entry *Message, s proto.Value) error {
 	keyField, valueField := entry.FieldByTag(1), entry.FieldByTag(2)
 	// assert(keyField != nil && valueField != nil)
 	keyWire := getFieldWireType(keyField.Kind, keyField.Repeated)
 	valueWire := getFieldWireType(valueField.Kind, valueField.Repeated)
 	// 暂不检查 keyField.Kind
 
-	var values [2]protoValue
+	var values [2]proto.Value
 	assigned := 0
-	dec := proto.NewDecoder(s)
+	dec := proto.NewDecoderAt(s.S, s.Off)
 	for !dec.EOF() && assigned != 3 {
 		tag, wire, e := dec.ReadTag()
 		if e < 0 {
-			return protowire.ParseError(e)
+			return dec.ErrorOf(e, 0, 0)
+		}
+		if tag != 1 && tag != 2 {
+			if e := dec.SkipField(tag, wire); e < 0 {
+				return dec.ErrorOf(e, tag, wire)
+			}
+			continue
 		}
-		val, e := readProtoValue(dec, wire)
+		val, e := dec.ReadValue(tag, wire)
 		if e < 0 {
-			return protowire.ParseError(e)
+			return dec.ErrorOf(e, tag, wire)
 		}
 		switch tag {
 		case 1:
@@ -156,10 +149,15 @@ func transProtoMapEntry(j *JsonBuilder, entry *Message, s []byte) error {
 	// key 缺省时按字段类型输出默认值（数值 key 为 "0"，字符串 key 为 ""）
 	if assigned&1 != 0 {
 		if keyField.Kind == StringKind {
-			transProtoString(j, values[0].s)
+			if err := st.limits.checkStringLen(len(values[0].S)); err != nil {
+				return err
+			}
+			if err := transProtoString(j, values[0].S, st.opts.UTF8); err != nil {
+				return err
+			}
 		} else {
 			j.AppendByte('"')
-			transProtoSimpleValue(j, keyField.Kind, values[0].x)
+			transProtoSimpleValue(j, keyField.Kind, values[0].X)
 			j.AppendByte('"')
 		}
 	} else {
@@ -176,39 +174,75 @@ func transProtoMapEntry(j *JsonBuilder, entry *Message, s []byte) error {
 
 	if assigned&2 != 0 {
 		switch valueField.Kind {
-		case StringKind:
-			transProtoString(j, values[1].s)
-		case BytesKind:
-			transProtoBytes(j, values[1].s)
-		case MessageKind:
-			err := transProtoMessage(j, proto.NewDecoder(values[1].s), valueField.Ref)
+		case StringKind, BytesKind:
+			if err := st.limits.checkStringLen(len(values[1].S)); err != nil {
+				return err
+			}
+			if valueField.Kind == StringKind {
+				if err := transProtoString(j, values[1].S, st.opts.UTF8); err != nil {
+					return err
+				}
+			} else if err := transProtoBytes(j, values[1].S, st.opts.URLSafeBytes); err != nil {
+				return err
+			}
+		case MessageKind, GroupKind:
+			err := st.transProtoMessage(j, proto.NewDecoderAt(values[1].S, values[1].Off), valueField.Ref)
 			if err != nil {
 				return err
 			}
 		default:
-			transProtoSimpleValue(j, valueField.Kind, values[1].x)
+			transProtoSimpleValue(j, valueField.Kind, values[1].X)
 		}
 	} else {
-		writeDefaultValue(j, valueField.Repeated, valueField.Kind)
+		writeDefaultValue(j, valueField)
 	}
 	return nil
 }
 
-func transProtoBytes(j *JsonBuilder, s []byte) {
+// transProtoBytes 以带 padding 的 base64 输出 bytes，urlSafe 时使用 URL-safe 字母表，分块写出失败时返回写出错误。
+func transProtoBytes(j *JsonBuilder, s []byte, urlSafe bool) error {
+	enc := base64.StdEncoding
+	if urlSafe {
+		enc = base64.URLEncoding
+	}
 	j.AppendByte('"')
-	n := base64.StdEncoding.EncodedLen(len(s))
-	j.Reserve(n)
-	m := len(j.buf)
-	d := j.buf[m : m+n]
-	base64.StdEncoding.Encode(d, s)
-	j.buf = j.buf[:m+n]
+	for len(s) != 0 {
+		chunk := s
+		if j.w != nil {
+			// 按 3 字节对齐分块，保证中间块不产生 padding
+			if size := max(j.size/4*3, 3); len(chunk) > size {
+				chunk = chunk[:size]
+			}
+		}
+		n := enc.EncodedLen(len(chunk))
+		j.Reserve(n)
+		m := len(j.buf)
+		d := j.buf[m : m+n]
+		enc.Encode(d, chunk)
+		j.buf = j.buf[:m+n]
+		s = s[len(chunk):]
+		if err := j.flushFull(); err != nil {
+			return err
+		}
+	}
 	j.AppendByte('"')
+	return nil
 }
 
-func transProtoString(j *JsonBuilder, s []byte) {
+// transProtoString 输出 JSON 字符串，合法 UTF-8 直接转义输出，不做复制。
+// 输出必须是合法 JSON，所以总是检查 UTF-8，UTF8Passthrough 在这里按 UTF8Replace 处理。
+func transProtoString(j *JsonBuilder, s []byte, mode UTF8Mode) error {
+	if mode == UTF8Passthrough {
+		mode = UTF8Replace
+	}
+	s, err := validUTF8(s, mode)
+	if err != nil {
+		return err
+	}
 	j.AppendByte('"')
 	j.AppendEscapedString(asString(s))
 	j.AppendByte('"')
+	return nil
 }
 
 // appendFloat 把浮点数按 protobuf JSON 规范追加到 j：
@@ -250,72 +284,452 @@ func transProtoSimpleValue(j *JsonBuilder, kind Kind, x uint64) {
 }
 
 // transProtoSingular 输出一个非重复字段的单值。
-func transProtoSingular(j *JsonBuilder, field *Field, o fieldScan) error {
+func (st *ptojState) transProtoSingular(j *JsonBuilder, field *Field, o fieldScan) error {
 	switch field.Kind {
-	case StringKind:
-		transProtoString(j, o.val.s)
-	case BytesKind:
-		transProtoBytes(j, o.val.s)
-	case MessageKind:
-		return transProtoMessage(j, proto.NewDecoder(o.val.s), field.Ref)
+	case StringKind, BytesKind:
+		if err := st.limits.checkStringLen(len(o.val.S)); err != nil {
+			return err
+		}
+		if field.Kind == StringKind {
+			return transProtoString(j, o.val.S, st.opts.UTF8)
+		}
+		return transProtoBytes(j, o.val.S, st.opts.URLSafeBytes)
+	case MessageKind, GroupKind:
+		return st.transProtoMessage(j, proto.NewDecoderAt(o.val.S, o.val.Off), field.Ref)
 	default:
-		transProtoSimpleValue(j, field.Kind, o.val.x)
+		transProtoSimpleValue(j, field.Kind, o.val.X)
 	}
 	return nil
 }
 
 // transProtoRepeated 输出一个重复字段的所有出现（跨非连续位置已拼接），含外层方括号。
-func transProtoRepeated(j *JsonBuilder, field *Field, occ []fieldScan) error {
+func (st *ptojState) transProtoRepeated(j *JsonBuilder, field *Field, occ []fieldScan) error {
 	j.AppendByte('[')
-	first := true
-	sep := func() {
-		if first {
-			first = false
-		} else {
+	n := 0
+	for _, o := range occ {
+		if err := st.transProtoElements(j, field, o, &n); err != nil {
+			return err
+		}
+	}
+	j.AppendByte(']')
+	return nil
+}
+
+// transProtoElements 输出重复字段一次出现中的元素（packed 出现可能包含多个元素），
+// n 为此前已输出的元素个数，用于决定分隔符与检查 MaxRepeated。
+func (st *ptojState) transProtoElements(j *JsonBuilder, field *Field, o fieldScan, n *int) error {
+	sep := func() error {
+		*n++
+		if *n > 1 {
 			j.AppendByte(',')
 		}
+		if err := j.flushFull(); err != nil {
+			return err
+		}
+		return st.limits.checkRepeated(*n)
 	}
-	for _, o := range occ {
-		switch field.Kind {
-		case StringKind:
-			sep()
-			transProtoString(j, o.val.s)
-		case BytesKind:
-			sep()
-			transProtoBytes(j, o.val.s)
-		case MessageKind:
-			sep()
-			if err := transProtoMessage(j, proto.NewDecoder(o.val.s), field.Ref); err != nil {
+	switch field.Kind {
+	case StringKind, BytesKind:
+		if err := sep(); err != nil {
+			return err
+		}
+		if err := st.limits.checkStringLen(len(o.val.S)); err != nil {
+			return err
+		}
+		if field.Kind == StringKind {
+			return transProtoString(j, o.val.S, st.opts.UTF8)
+		}
+		return transProtoBytes(j, o.val.S, st.opts.URLSafeBytes)
+	case MessageKind, GroupKind:
+		if err := sep(); err != nil {
+			return err
+		}
+		if err := st.transProtoMessage(j, proto.NewDecoderAt(o.val.S, o.val.Off), field.Ref); err != nil {
+			return err
+		}
+	default:
+		// 数值/bool：可能是 packed (BytesType) 或 unpacked (单元素)
+		if int(field.Kind) >= len(wireTypeOfKind) {
+			return ErrTypeMismatch
+		}
+		if o.wire == protowire.BytesType {
+			dec := proto.NewDecoderAt(o.val.S, o.val.Off)
+			elemWire := wireTypeOfKind[field.Kind]
+			for !dec.EOF() {
+				v, e := dec.ReadValue(0, elemWire)
+				if e < 0 {
+					return dec.ErrorOf(e, field.Tag, o.wire)
+				}
+				if err := sep(); err != nil {
+					return err
+				}
+				transProtoSimpleValue(j, field.Kind, v.X)
+			}
+		} else {
+			if err := sep(); err != nil {
 				return err
 			}
-		default:
-			// 数值/bool：可能是 packed (BytesType) 或 unpacked (单元素)
-			if int(field.Kind) >= len(wireTypeOfKind) {
-				return ErrTypeMismatch
+			transProtoSimpleValue(j, field.Kind, o.val.X)
+		}
+	}
+	return nil
+}
+
+// orderedEmitter 边读边按字段定义顺序输出 JSON，不收集各字段的出现，输出与两遍算法一致：
+// 未出现的字段输出默认值，相邻重复出现的非重复字段 last-one-wins。
+// 规范编码器按 tag 升序写出字段、同一字段的出现彼此相邻，因此读到 tag 为 T 的字段时，
+// 尚未输出、tag 小于 T 且未出现的字段视为缺失并输出默认值；定义在前但 tag 大于 T 的字段还没有轮到，
+// 此时读到的字段先暂存到 held，轮到时再输出。声明顺序与 tag 顺序不同的消息因此也能边读边输出。
+// 已经输出的字段再次出现（字段不按 tag 升序或同一字段的出现不相邻）时返回 ErrFieldOrder，此时尚未为它输出任何内容。
+type orderedEmitter struct {
+	st   *ptojState
+	j    *JsonBuilder
+	msg  *Message
+	more bool
+	// retry 时可能被之后乱序出现的字段改变结果的错误（非重复字段的值错误、缺失 required 字段）也返回 ErrFieldOrder，
+	// 由调用方改用两遍算法得到准确的结果
+	retry bool
+	next  int // 尚未输出的第一个字段
+	cur   int // 正在输出的字段，-1 表示没有
+	n     int // cur 已输出的元素个数
+	// 非重复字段的值暂存到遇到下一个字段为止
+	pending fieldScan
+	// held 是提前出现、尚未轮到输出的字段
+	held []heldScan
+	// copyValues 时输入在下一次读取后失效，pending 与 held 的值分别复制到 pendingBuf 与 heldBuf
+	copyValues bool
+	pendingBuf []byte
+	heldBuf    []byte
+}
+
+// heldScan 是暂存的第 idx 个字段的一次出现，copyValues 时值的内容为 heldBuf[start:end]。
+type heldScan struct {
+	idx        int
+	scan       fieldScan
+	start, end int
+}
+
+func (e *orderedEmitter) begin(st *ptojState, j *JsonBuilder, msg *Message) {
+	*e = orderedEmitter{st: st, j: j, msg: msg, cur: -1}
+	j.AppendByte('{')
+}
+
+func (e *orderedEmitter) emitHeader(name string) error {
+	j := e.j
+	if err := j.flushFull(); err != nil {
+		return err
+	}
+	if e.more {
+		j.AppendByte(',')
+	} else {
+		e.more = true
+	}
+	j.AppendByte('"')
+	j.AppendString(name)
+	j.AppendByte('"')
+	j.AppendByte(':')
+	return nil
+}
+
+// advance 按定义顺序输出从 next 开始、tag 小于 tag 的字段（final 时为剩余的全部字段）：
+// 暂存过的字段输出其值，其它字段都未出现，输出默认值。
+func (e *orderedEmitter) advance(tag uint32, final bool) error {
+	for e.next < len(e.msg.Fields) {
+		field := &e.msg.Fields[e.next]
+		if !final && field.Tag >= tag {
+			return nil
+		}
+		if e.isHeld(e.next) {
+			if err := e.start(e.next); err != nil {
+				return err
 			}
-			if o.wire == protowire.BytesType {
-				dec := proto.NewDecoder(o.val.s)
-				elemWire := wireTypeOfKind[field.Kind]
-				for !dec.EOF() {
-					v, e := readProtoValue(dec, elemWire)
-					if e < 0 {
-						return protowire.ParseError(e)
-					}
-					sep()
-					transProtoSimpleValue(j, field.Kind, v.x)
-				}
-			} else {
-				sep()
-				transProtoSimpleValue(j, field.Kind, o.val.x)
+			if err := e.closeCur(); err != nil {
+				return err
+			}
+			continue
+		}
+		e.next++
+		if field.Omit == OmitAlways {
+			continue
+		}
+		if field.Required {
+			if e.retry && !final {
+				return ErrFieldOrder
 			}
+			return &RequiredFieldError{Path: field.Name}
+		}
+		if field.Omit >= OmitEmpty {
+			continue
 		}
+		if err := e.emitHeader(field.Name); err != nil {
+			return err
+		}
+		writeDefaultValue(e.j, field)
+	}
+	return nil
+}
+
+func (e *orderedEmitter) isHeld(idx int) bool {
+	for i := range e.held {
+		if e.held[i].idx == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// hold 暂存第 idx 个字段的一次出现。
+func (e *orderedEmitter) hold(idx int, wire protowire.Type, val proto.Value) {
+	h := heldScan{idx: idx, scan: fieldScan{wire: wire, val: val}}
+	if e.copyValues {
+		h.start = len(e.heldBuf)
+		e.heldBuf = append(e.heldBuf, val.S...)
+		h.end = len(e.heldBuf)
+		h.scan.val.S = nil
+	}
+	e.held = append(e.held, h)
+}
+
+// start 开始输出第 idx 个字段（idx 为 next）并输出它暂存的出现，之后的出现由 value 追加。
+func (e *orderedEmitter) start(idx int) error {
+	field := &e.msg.Fields[idx]
+	e.next = idx + 1
+	e.cur = idx
+	e.n = 0
+	if err := e.emitHeader(field.Name); err != nil {
+		return err
+	}
+	if field.Kind == MapKind {
+		e.j.AppendByte('{')
+	} else if field.Repeated {
+		e.j.AppendByte('[')
+	}
+	k := 0
+	for _, h := range e.held {
+		if h.idx != idx {
+			e.held[k] = h
+			k++
+			continue
+		}
+		if e.copyValues {
+			h.scan.val.S = e.heldBuf[h.start:h.end]
+		}
+		if err := e.value(h.scan.wire, h.scan.val); err != nil {
+			return err
+		}
+	}
+	e.held = e.held[:k]
+	if k == 0 {
+		e.heldBuf = e.heldBuf[:0]
+	}
+	return nil
+}
+
+func (e *orderedEmitter) closeCur() error {
+	if e.cur < 0 {
+		return nil
+	}
+	field := &e.msg.Fields[e.cur]
+	e.cur = -1
+	switch {
+	case field.Kind == MapKind:
+		e.j.AppendByte('}')
+	case field.Repeated:
+		e.j.AppendByte(']')
+	default:
+		return withFieldPath(e.st.transProtoSingular(e.j, field, e.pending), field.Name)
 	}
-	j.AppendByte(']')
 	return nil
 }
 
-func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
-	// 两遍处理：先收集每个字段的所有出现，再按字段定义顺序输出。
+// field 处理 tag 为 tag 的第 idx 个字段的一次出现，wire 已经过 acceptFieldWire 检查。
+func (e *orderedEmitter) field(idx int, tag uint32, wire protowire.Type, val proto.Value) error {
+	if idx == e.cur {
+		return e.value(wire, val)
+	}
+	if idx < e.next {
+		return ErrFieldOrder
+	}
+	if err := e.closeCur(); err != nil {
+		if e.retry {
+			return ErrFieldOrder
+		}
+		return err
+	}
+	if err := e.advance(tag, false); err != nil {
+		return err
+	}
+	if idx != e.next {
+		e.hold(idx, wire, val)
+		return nil
+	}
+	if err := e.start(idx); err != nil {
+		return err
+	}
+	return e.value(wire, val)
+}
+
+// value 输出正在输出的字段 cur 的一次出现，非重复字段只暂存，结束时输出最后一次出现。
+func (e *orderedEmitter) value(wire protowire.Type, val proto.Value) error {
+	field := &e.msg.Fields[e.cur]
+	st, j := e.st, e.j
+	switch {
+	case field.Kind == MapKind:
+		e.n++
+		if err := st.limits.checkMapEntries(e.n); err != nil {
+			return err
+		}
+		if e.n > 1 {
+			j.AppendByte(',')
+		}
+		if err := j.flushFull(); err != nil {
+			return err
+		}
+		if err := st.transProtoMapEntry(j, field.Ref, val); err != nil {
+			return withFieldPath(err, field.Name)
+		}
+	case field.Repeated:
+		if err := st.transProtoElements(j, field, fieldScan{wire: wire, val: val}, &e.n); err != nil {
+			return withFieldPath(err, field.Name)
+		}
+	default:
+		if e.copyValues {
+			e.pendingBuf = append(e.pendingBuf[:0], val.S...)
+			val.S = e.pendingBuf
+		}
+		e.pending = fieldScan{wire: wire, val: val}
+	}
+	return nil
+}
+
+// end 输出剩余的字段并结束对象。
+func (e *orderedEmitter) end() error {
+	if err := e.closeCur(); err != nil {
+		return err
+	}
+	if err := e.advance(0, true); err != nil {
+		return err
+	}
+	e.j.AppendByte('}')
+	return nil
+}
+
+// inFieldOrder 判断 p 中的字段及其中所有子消息（含 map entry 与 group）的字段是否都满足 orderedEmitter 的顺序要求，
+// 整棵消息树只需在最外层检查一次。depth 是还允许的嵌套层数，为 0 时不限制。
+// 只读取 tag 并跳过值，不做分配；遇到任何错误或超出 depth 都返回 false，交由两遍算法报告。
+func inFieldOrder(p proto.Decoder, msg *Message, depth int) bool {
+	if depth == 1 {
+		return false
+	}
+	last := -1
+	for !p.EOF() {
+		tag, wire, e := p.ReadTag()
+		if e < 0 {
+			return false
+		}
+		idx := msg.FieldIndexByTag(tag)
+		var field *Field
+		if idx >= 0 {
+			field = &msg.Fields[idx]
+		}
+		if field == nil || field.Ref == nil || field.Omit == OmitAlways || !acceptFieldWire(field, wire) {
+			if e := p.SkipField(tag, wire); e < 0 {
+				return false
+			}
+		} else {
+			// 子消息、map entry 与 group 递归检查
+			val, e := p.ReadValue(tag, wire)
+			if e < 0 || !inFieldOrder(*proto.NewDecoder(val.S), field.Ref, depth-1) {
+				return false
+			}
+		}
+		if field == nil {
+			continue
+		}
+		if !acceptFieldWire(field, wire) {
+			return false
+		}
+		if field.Omit == OmitAlways {
+			continue
+		}
+		if idx < last {
+			return false
+		}
+		last = idx
+	}
+	return true
+}
+
+// transProtoOrdered 是 transProtoMessage 的单遍输出路径，字段不满足 orderedEmitter 的顺序要求时返回 ErrFieldOrder，
+// retry 见 orderedEmitter。
+func (st *ptojState) transProtoOrdered(j *JsonBuilder, p *proto.Decoder, msg *Message, retry bool) error {
+	var e orderedEmitter
+	e.begin(st, j, msg)
+	e.retry = retry
+	for !p.EOF() {
+		tag, wire, c := p.ReadTag()
+		if c < 0 {
+			return p.ErrorOf(c, 0, 0)
+		}
+		fieldIdx := msg.FieldIndexByTag(tag)
+		if fieldIdx < 0 || msg.Fields[fieldIdx].Omit == OmitAlways {
+			if c := p.SkipField(tag, wire); c < 0 {
+				return p.ErrorOf(c, tag, wire)
+			}
+			continue
+		}
+		val, c := p.ReadValue(tag, wire)
+		if c < 0 {
+			return p.ErrorOf(c, tag, wire)
+		}
+		if !acceptFieldWire(&msg.Fields[fieldIdx], wire) {
+			return ErrInvalidWireType
+		}
+		if err := e.field(fieldIdx, tag, wire, val); err != nil {
+			return err
+		}
+	}
+	return e.end()
+}
+
+func (st *ptojState) transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
+	// 规范编码器按定义顺序写出字段，先尝试边读边输出；字段乱序时撤销已输出的内容改用两遍处理。
+	// 输入中出现过乱序后不再尝试，避免嵌套消息被反复重新处理。
+	// 已写出到 io.Writer 的内容无法撤销，此时先用 inFieldOrder 确认字段有序：
+	// 最外层的消息检查整棵消息树，其中嵌套的消息直接使用检查的结果，不再重复扫描。
+	switch {
+	case j.w != nil:
+		if !st.checked {
+			st.checked = true
+			defer func() {
+				st.checked = false
+			}()
+			depth := 0
+			if st.limits.MaxDepth > 0 {
+				depth = st.limits.MaxDepth - st.depth + 2
+			}
+			st.treeOrdered = inFieldOrder(*p, msg, depth)
+		}
+		if st.treeOrdered {
+			return st.transProtoOrdered(j, p, msg, false)
+		}
+	case !st.unordered:
+		mark, start := j.Len(), *p
+		err := st.transProtoOrdered(j, p, msg, true)
+		if err != ErrFieldOrder {
+			return err
+		}
+		st.unordered = true
+		j.buf = j.buf[:mark]
+		*p = start
+	}
+
+	// 否则两遍处理：先收集每个字段的所有出现，再按字段定义顺序输出。
 	// 这样才能正确拼接非连续出现的重复字段，并对非重复字段实现 last-one-wins。
 	const preAllocSize = 16
 	var preAlloc [preAllocSize][]fieldScan
@@ -329,16 +743,20 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 	for !p.EOF() {
 		tag, wire, e := p.ReadTag()
 		if e < 0 {
-			return protowire.ParseError(e)
-		}
-		val, e := readProtoValue(p, wire)
-		if e < 0 {
-			return protowire.ParseError(e)
+			return p.ErrorOf(e, 0, 0)
 		}
 		fieldIdx := msg.FieldIndexByTag(tag)
 		if fieldIdx < 0 {
+			// 未知字段（包括新版本或 proto2 的 group 字段）直接跳过
+			if e := p.SkipField(tag, wire); e < 0 {
+				return p.ErrorOf(e, tag, wire)
+			}
 			continue
 		}
+		val, e := p.ReadValue(tag, wire)
+		if e < 0 {
+			return p.ErrorOf(e, tag, wire)
+		}
 		field := &msg.Fields[fieldIdx]
 		if !acceptFieldWire(field, wire) {
 			return ErrInvalidWireType
@@ -346,6 +764,13 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 		occurrences[fieldIdx] = append(occurrences[fieldIdx], fieldScan{wire: wire, val: val})
 	}
 
+	err := checkRequired(msg, func(i int) bool {
+		return len(occurrences[i]) != 0
+	})
+	if err != nil {
+		return err
+	}
+
 	j.AppendByte('{')
 	more := false
 	emitHeader := func(name string) {
@@ -364,6 +789,9 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 		if field.Omit == OmitAlways {
 			continue
 		}
+		if err := j.flushFull(); err != nil {
+			return err
+		}
 		occ := occurrences[i]
 		switch {
 		case field.Kind == MapKind:
@@ -375,15 +803,21 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 				j.AppendString("{}")
 				continue
 			}
+			if err := st.limits.checkMapEntries(len(occ)); err != nil {
+				return err
+			}
 			emitHeader(field.Name)
 			j.AppendByte('{')
 			for k, o := range occ {
 				if k > 0 {
 					j.AppendByte(',')
 				}
-				if err := transProtoMapEntry(j, field.Ref, o.val.s); err != nil {
+				if err := j.flushFull(); err != nil {
 					return err
 				}
+				if err := st.transProtoMapEntry(j, field.Ref, o.val); err != nil {
+					return withFieldPath(err, field.Name)
+				}
 			}
 			j.AppendByte('}')
 		case field.Repeated:
@@ -396,8 +830,8 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 				continue
 			}
 			emitHeader(field.Name)
-			if err := transProtoRepeated(j, field, occ); err != nil {
-				return err
+			if err := st.transProtoRepeated(j, field, occ); err != nil {
+				return withFieldPath(err, field.Name)
 			}
 		default:
 			if len(occ) == 0 {
@@ -405,13 +839,13 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 					continue
 				}
 				emitHeader(field.Name)
-				writeDefaultValue(j, false, field.Kind)
+				writeDefaultValue(j, field)
 				continue
 			}
 			// proto3 语义：非重复字段重复出现时 last-one-wins。
 			emitHeader(field.Name)
-			if err := transProtoSingular(j, field, occ[len(occ)-1]); err != nil {
-				return err
+			if err := st.transProtoSingular(j, field, occ[len(occ)-1]); err != nil {
+				return withFieldPath(err, field.Name)
 			}
 		}
 	}
@@ -419,7 +853,66 @@ func transProtoMessage(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
 	return nil
 }
 
-// TranscodeToJson 通过 proto.Decoder 解析 pb，并且追加到 JsonBuilder 中
+// ToJsonOptions 控制 proto->json 的转码行为，零值表示默认行为。
+type ToJsonOptions struct {
+	// Limits 为 nil 时使用 DefaultLimits
+	Limits *Limits
+	// UTF8 控制 string 字段含非法 UTF-8 时的处理，默认返回 ErrInvalidUTF8。
+	// 为保证输出是合法 JSON，所有 string 字段都会检查（不看 ValidateUTF8），UTF8Passthrough 按 UTF8Replace 处理。
+	UTF8 UTF8Mode
+	// URLSafeBytes 为 true 时 bytes 字段使用 URL-safe 字母表的 base64（仍带 padding），默认为标准字母表
+	URLSafeBytes bool
+}
+
+var defaultToJsonOptions ToJsonOptions
+
+// ptojState 保存一次 proto->json 转码过程中的选项与嵌套状态。
+type ptojState struct {
+	opts   *ToJsonOptions
+	limits *Limits
+	depth  int
+	// unordered 为 true 时输入中已出现过不按定义顺序排列的字段，之后的消息直接两遍处理
+	unordered bool
+	// checked 表示流式输出时正在处理已由 inFieldOrder 检查过的消息树，treeOrdered 是检查的结果
+	checked     bool
+	treeOrdered bool
+}
+
+func newPtojState(opts *ToJsonOptions) ptojState {
+	limits := opts.Limits
+	if limits == nil {
+		limits = &DefaultLimits
+	}
+	return ptojState{
+		opts:   opts,
+		limits: limits,
+	}
+}
+
+func (st *ptojState) enter() error {
+	st.depth++
+	return st.limits.checkDepth(st.depth)
+}
+
+func (st *ptojState) leave() {
+	st.depth--
+}
+
+// Transcode 按 o 指定的选项把 pb 转译为 JSON 并追加到 j 中，见 TranscodeToJson。
+func (o *ToJsonOptions) Transcode(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
+	st := newPtojState(o)
+	if err := st.limits.checkInputSize(p.Len()); err != nil {
+		return err
+	}
+	if err := st.transProtoMessage(j, p, msg); err != nil {
+		return err
+	}
+	return j.Flush()
+}
+
+// TranscodeToJson 通过 proto.Decoder 解析 pb，并且追加到 JsonBuilder 中。
+// 如果 j 由 NewStreamJsonBuilder 创建，输出会边转码边写出，返回前写出全部剩余内容。
+// 转码受 DefaultLimits 限制，需要其它限制时使用 ToJsonOptions。
 func TranscodeToJson(j *JsonBuilder, p *proto.Decoder, msg *Message) error {
-	return transProtoMessage(j, p, msg)
+	return defaultToJsonOptions.Transcode(j, p, msg)
 }
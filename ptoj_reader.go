@@ -0,0 +1,119 @@
+package jsonpb
+
+import (
+	"errors"
+
+	"github.com/vizee/jsonpb/proto"
+)
+
+var (
+	ErrFieldOrder = errors.New("field out of order")
+)
+
+func (st *ptojState) checkReaderSize(r *proto.ReaderDecoder) error {
+	if st.limits.MaxInputSize > 0 && r.Offset() > int64(st.limits.MaxInputSize) {
+		return &LimitError{Limit: "input size", Max: st.limits.MaxInputSize}
+	}
+	return nil
+}
+
+// limitValue 在读取 field（nil 表示未知字段）的值之前设置 r 读入窗口的单个值的上限，返回超出时报告的错误：
+// 值不超过剩余的 MaxInputSize，string/bytes 值不超过 MaxStringLen，声明了超大长度的值因此不会被读入内存。
+func (st *ptojState) limitValue(r *proto.ReaderDecoder, field *Field) error {
+	n, err := 0, error(nil)
+	if st.limits.MaxStringLen > 0 && field != nil && (field.Kind == StringKind || field.Kind == BytesKind) {
+		n, err = st.limits.MaxStringLen, &LimitError{Limit: "string length", Max: st.limits.MaxStringLen}
+	}
+	if st.limits.MaxInputSize > 0 {
+		// 剩余为 0 时仍按 1 设置（0 表示不限制），多读入的 1 字节由之后的输入大小检查报告
+		rest := max(st.limits.MaxInputSize-int(r.Offset()), 1)
+		if n == 0 || rest < n {
+			n, err = rest, &LimitError{Limit: "input size", Max: st.limits.MaxInputSize}
+		}
+	}
+	r.SetMaxLen(n)
+	return err
+}
+
+// transProtoStream 边读边输出顶层消息，只有子消息等 length-delimited 值会整体读入内存，
+// 其大小受 MaxInputSize 与 MaxStringLen 限制。输出与 transProtoMessage 一致（按字段定义顺序、未出现字段输出默认值），
+// 字段按 tag 升序且同一字段的出现彼此相邻时（规范编码器的输出）总能边读边输出，见 orderedEmitter：
+// 声明顺序先于 tag 顺序的字段提前出现时会复制暂存到轮到它为止，暂存的内容同样受 MaxInputSize 限制。
+// tag 小于已读到的字段、且已按缺失输出了默认值的字段，以及已输出的字段再次出现时，无法在不缓冲整个输入的情况下输出，
+// 返回 ErrFieldOrder，此前已写出的内容不会撤销。
+func (st *ptojState) transProtoStream(j *JsonBuilder, r *proto.ReaderDecoder, msg *Message) error {
+	if err := st.enter(); err != nil {
+		return err
+	}
+	defer st.leave()
+
+	var e orderedEmitter
+	e.begin(st, j, msg)
+	e.copyValues = true
+	for !r.EOF() {
+		if err := st.checkReaderSize(r); err != nil {
+			return err
+		}
+		tag, wire, err := r.ReadTag()
+		if err != nil {
+			return err
+		}
+		fieldIdx := msg.FieldIndexByTag(tag)
+		if fieldIdx < 0 || msg.Fields[fieldIdx].Omit == OmitAlways {
+			lerr := st.limitValue(r, nil)
+			if err := r.SkipField(tag, wire); err != nil {
+				return valueLimitError(err, lerr)
+			}
+			continue
+		}
+		field := &msg.Fields[fieldIdx]
+		if !acceptFieldWire(field, wire) {
+			return ErrInvalidWireType
+		}
+		if fieldIdx < e.next && fieldIdx != e.cur {
+			return ErrFieldOrder
+		}
+		lerr := st.limitValue(r, field)
+		val, err := r.ReadValue(tag, wire)
+		if err != nil {
+			return valueLimitError(err, lerr)
+		}
+		if err := e.field(fieldIdx, tag, wire, val); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := st.checkReaderSize(r); err != nil {
+		return err
+	}
+	return e.end()
+}
+
+// valueLimitError 把超出 limitValue 所设上限的读取错误替换为对应的 LimitError，其它错误原样返回。
+func valueLimitError(err error, lerr error) error {
+	if lerr != nil && errors.Is(err, proto.ErrTooLarge) {
+		return lerr
+	}
+	return err
+}
+
+// TranscodeReader 按 o 指定的选项从 r 流式读取 pb 并转译为 JSON，见 TranscodeReaderToJson。
+func (o *ToJsonOptions) TranscodeReader(j *JsonBuilder, r *proto.ReaderDecoder, msg *Message) error {
+	st := newPtojState(o)
+	if err := st.transProtoStream(j, r, msg); err != nil {
+		return err
+	}
+	return j.Flush()
+}
+
+// TranscodeReaderToJson 从 proto.ReaderDecoder 流式读取 pb，并且追加到 JsonBuilder 中。
+// 顶层消息的字段边读边输出，只有单个子消息/字符串等 length-delimited 值需要整体读入内存，
+// 配合 NewStreamJsonBuilder 可以用有限内存处理超大的 repeated 列表。
+// 规范编码器的输出（字段按 tag 升序、同一字段连续出现，TranscodeToProto 的输出也是如此）总能转译；
+// 字段不按 tag 升序出现时可能返回 ErrFieldOrder，此时 j 中已有部分输出，见 transProtoStream。
+// 转译时按 Limits 设置 r 的 SetMaxLen。
+func TranscodeReaderToJson(j *JsonBuilder, r *proto.ReaderDecoder, msg *Message) error {
+	return defaultToJsonOptions.TranscodeReader(j, r, msg)
+}
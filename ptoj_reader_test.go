@@ -0,0 +1,203 @@
+package jsonpb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func transProtoStreamCase(p string, msg *Message, size int) (string, error) {
+	var j JsonBuilder
+	r := proto.NewReaderDecoder(iotest.OneByteReader(bytes.NewReader(decodeBytes(p))), size)
+	err := TranscodeReaderToJson(&j, r, msg)
+	if err != nil {
+		return "", err
+	}
+	return j.String(), nil
+}
+
+func TestTranscodeReaderToJson(t *testing.T) {
+	const complexProto = `090000000000c05e40150000f642187b207b287b307b38f60140f6014d7b000000517b000000000000005d7b000000617b00000000000000680172036f6b6b7a030102038201050a016b10018a010e0a017512090a03616263101718018a01050a017612009201090a03656667101718019a0103010203a201090a03616263100c1801a20100a201070a036566671017`
+	tests := []struct {
+		name string
+		p    string
+		msg  *Message
+	}{
+		{name: "empty", p: "", msg: getTestSimpleMessage()},
+		{name: "simple", p: "0a03626f6210171801", msg: getTestSimpleMessage()},
+		{name: "simple2", p: "0a03626f6210171801", msg: getTestSimpleMessage2()},
+		{name: "complex", p: complexProto, msg: getTestComplexMessage()},
+		{name: "default", p: "", msg: getTestComplexMessage()},
+		{name: "gap_defaults", p: "680172036f6b6b", msg: getTestComplexMessage()},
+		{name: "last_one_wins", p: "18051809", msg: NewMessage("M", []Field{{Name: "f3", Kind: Int32Kind, Tag: 3}}, true, true)},
+		{name: "unknown_bytes", p: "fa01036162630a03626f62", msg: getTestSimpleMessage()},
+		// 声明顺序与 tag 顺序不同，规范编码按 tag 升序
+		{name: "decl_order", p: "08011002", msg: NewMessage("M", []Field{
+			{Name: "b", Kind: Int32Kind, Tag: 2},
+			{Name: "a", Kind: Int32Kind, Tag: 1},
+		}, true, true)},
+		{name: "decl_order_sparse", p: "0a0378797a" + "0a0177" + "1803" + "22020102" + "2807", msg: NewMessage("M", []Field{
+			{Name: "e", Kind: Int32Kind, Tag: 5},
+			{Name: "b", Kind: Int32Kind, Tag: 2},
+			{Name: "a", Kind: StringKind, Tag: 1, Repeated: true},
+			{Name: "c", Kind: Int32Kind, Tag: 3},
+			{Name: "d", Kind: BytesKind, Tag: 4},
+		}, true, true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := transProtoMessageCase(tt.p, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, size := range []int{1, 3, 0} {
+				got, err := transProtoStreamCase(tt.p, tt.msg, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("size=%d: got %s, want %s", size, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscodeReaderToJson_roundTrip(t *testing.T) {
+	msg := NewMessage("M", []Field{
+		{Name: "s", Kind: StringKind, Tag: 1},
+		{Name: "c", Kind: Int32Kind, Tag: 2},
+		{Name: "n", Kind: Int32Kind, Tag: 4},
+		{Name: "b", Kind: BoolKind, Tag: 6},
+		{Name: "d", Kind: DoubleKind, Tag: 7},
+		{Name: "t", Kind: StringKind, Tag: 8},
+		{Name: "sub", Kind: MessageKind, Tag: 9, Ref: getTestSimpleMessage()},
+	}, true, true)
+	for _, s := range []string{
+		`{"s":"x","c":1,"n":2,"d":1.5,"t":"y","b":true}`,
+		`{"sub":{"male":true,"name":"bob"},"t":"y","s":"x"}`,
+		`{"s":"x","c":1,"s":"z","b":true}`,
+	} {
+		var p proto.Encoder
+		if err := TranscodeToProto(&p, jsonlit.NewIter([]byte(s)), msg); err != nil {
+			t.Fatal(err)
+		}
+		var want JsonBuilder
+		if err := TranscodeToJson(&want, proto.NewDecoder(p.Bytes()), msg); err != nil {
+			t.Fatal(err)
+		}
+		var w chunkWriter
+		if err := TranscodeReaderToJson(NewStreamJsonBuilder(&w, 0), proto.NewReaderDecoder(bytes.NewReader(p.Bytes()), 0), msg); err != nil {
+			t.Fatalf("%s: TranscodeReaderToJson() error = %v", s, err)
+		}
+		if string(w.out) != want.String() {
+			t.Errorf("%s: TranscodeReaderToJson() = %s, want %s", s, w.out, want.String())
+		}
+	}
+}
+
+func TestTranscodeReaderToJson_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       string
+		msg     *Message
+		wantErr error
+	}{
+		{name: "out_of_order", p: "10170a03626f62", msg: getTestSimpleMessage(), wantErr: ErrFieldOrder},
+		{name: "split_repeated", p: "9a0103616161" + "1805" + "9a0103626262", msg: NewMessage("M", []Field{
+			{Name: "f3", Kind: Int32Kind, Tag: 3},
+			{Name: "f19", Kind: StringKind, Tag: 19, Repeated: true},
+		}, true, true), wantErr: ErrFieldOrder},
+		{name: "truncated", p: "0a05626f62", msg: getTestSimpleMessage(), wantErr: io.ErrUnexpectedEOF},
+		{name: "bad_wire", p: "0d00000000", msg: getTestSimpleMessage(), wantErr: ErrInvalidWireType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transProtoStreamCase(tt.p, tt.msg, 0); !errors.Is(err, tt.wantErr) {
+				t.Errorf("transProtoStreamCase() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTranscodeReaderToJson_valueLimits(t *testing.T) {
+	msg := NewMessage("M", []Field{
+		{Name: "s", Tag: 1, Kind: StringKind},
+		{Name: "sub", Tag: 2, Kind: MessageKind, Ref: getTestSimpleMessage()},
+	}, true, true)
+	const huge = 8 << 20
+	tests := []struct {
+		name   string
+		tag    uint32
+		limits Limits
+		limit  string
+	}{
+		{name: "string", tag: 1, limits: Limits{MaxInputSize: 1024, MaxStringLen: 16}, limit: "string length"},
+		{name: "string_input", tag: 1, limits: Limits{MaxInputSize: 1024}, limit: "input size"},
+		{name: "message", tag: 2, limits: Limits{MaxInputSize: 1024, MaxStringLen: 16}, limit: "input size"},
+		{name: "unknown", tag: 3, limits: Limits{MaxInputSize: 1024}, limit: "input size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enc proto.Encoder
+			enc.EmitBytes(tt.tag, make([]byte, huge))
+			r := &countReader{r: bytes.NewReader(enc.Bytes())}
+			opts := ToJsonOptions{Limits: &tt.limits}
+			err := opts.TranscodeReader(&JsonBuilder{}, proto.NewReaderDecoder(r, 0), msg)
+			var le *LimitError
+			if !errors.As(err, &le) || le.Limit != tt.limit {
+				t.Fatalf("TranscodeReader() error = %v, want %s limit", err, tt.limit)
+			}
+			// 未知字段不读入内存，但会读过整个值后才发现超出输入大小
+			if tt.tag != 3 && r.n > 16<<10 {
+				t.Fatalf("read %d bytes before the limit error", r.n)
+			}
+		})
+	}
+}
+
+func TestTranscodeReaderToJson_largeList(t *testing.T) {
+	msg := NewMessage("List", []Field{
+		{Name: "items", Tag: 1, Kind: MessageKind, Repeated: true, Ref: getTestSimpleMessage()},
+	}, true, true)
+	var enc proto.Encoder
+	const n = 10000
+	for i := 0; i < n; i++ {
+		enc.EmitBytes(1, decodeBytes("0a03626f621017"))
+	}
+	var w chunkWriter
+	r := proto.NewReaderDecoder(bytes.NewReader(enc.Bytes()), 64)
+	if err := TranscodeReaderToJson(NewStreamJsonBuilder(&w, 256), r, msg); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"items":[` + strings.TrimSuffix(strings.Repeat(`{"name":"bob","age":23},`, n), ",") + `]}`
+	if string(w.out) != want {
+		t.Fatal("unexpected output")
+	}
+
+	opts := ToJsonOptions{Limits: &Limits{MaxInputSize: 1000}}
+	r = proto.NewReaderDecoder(bytes.NewReader(enc.Bytes()), 64)
+	if err := opts.TranscodeReader(&JsonBuilder{}, r, msg); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("TranscodeReader() error = %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestTranscodeReaderToJson_nestedProtoError(t *testing.T) {
+	msg := NewMessage("", []Field{
+		{Name: "name", Tag: 1, Kind: StringKind},
+		{Name: "sub", Tag: 2, Kind: MessageKind, Ref: getTestSimpleMessage()},
+	}, true, true)
+	var j JsonBuilder
+	r := proto.NewReaderDecoder(bytes.NewReader(decodeBytes("0a0161"+"1202"+"1080")), 0)
+	err := TranscodeReaderToJson(&j, r, msg)
+	var perr *proto.Error
+	if !errors.As(err, &perr) || perr.Offset != 6 || perr.Tag != 2 {
+		t.Fatalf("TranscodeReaderToJson() error = %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package jsonpb
 
 import (
+	"bytes"
 	"encoding/hex"
-	"reflect"
+	"errors"
+	"io"
 	"testing"
 
 	"github.com/vizee/jsonpb/proto"
@@ -17,49 +19,16 @@ func decodeBytes(s string) []byte {
 	return b
 }
 
-func readProtoValueCase(s string, wire protowire.Type) (protoValue, int) {
-	return readProtoValue(proto.NewDecoder(decodeBytes(s)), wire)
-}
-
-func Test_readProtoValueCase(t *testing.T) {
-	type args struct {
-		s    string
-		wire protowire.Type
-	}
-	tests := []struct {
-		name  string
-		args  args
-		want  protoValue
-		want1 int
-	}{
-		{name: "varint", args: args{s: "7b", wire: protowire.VarintType}, want: protoValue{x: 123}},
-		{name: "fixed32", args: args{s: "7b000000", wire: protowire.Fixed32Type}, want: protoValue{x: 123}},
-		{name: "fixed64", args: args{s: "7b00000000000000", wire: protowire.Fixed64Type}, want: protoValue{x: 123}},
-		{name: "bytes", args: args{s: "036f6b6b", wire: protowire.BytesType}, want: protoValue{s: []byte("okk")}},
-		{name: "bad_wire", args: args{s: "", wire: protowire.StartGroupType}, want: protoValue{}, want1: -100},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := readProtoValueCase(tt.args.s, tt.args.wire)
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("readProtoValueCase() got = %v, want %v", got, tt.want)
-			}
-			if got1 != tt.want1 {
-				t.Errorf("readProtoValueCase() got1 = %v, want %v", got1, tt.want1)
-			}
-		})
-	}
-}
-
-func transProtoBytesCase(s string) string {
+func transProtoBytesCase(s string, urlSafe bool) string {
 	var j JsonBuilder
-	transProtoBytes(&j, decodeBytes(s))
+	transProtoBytes(&j, decodeBytes(s), urlSafe)
 	return j.String()
 }
 
 func Test_transProtoBytesCase(t *testing.T) {
 	type args struct {
-		s string
+		s       string
+		urlSafe bool
 	}
 	tests := []struct {
 		name string
@@ -68,10 +37,13 @@ func Test_transProtoBytesCase(t *testing.T) {
 	}{
 		{name: "empty", args: args{s: ""}, want: `""`},
 		{name: "hello", args: args{s: "68656c6c6f"}, want: `"aGVsbG8="`},
+		{name: "std", args: args{s: "fbfffb"}, want: `"+//7"`},
+		{name: "url_safe", args: args{s: "fbfffb", urlSafe: true}, want: `"-__7"`},
+		{name: "url_safe_padded", args: args{s: "fbff", urlSafe: true}, want: `"-_8="`},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := transProtoBytesCase(tt.args.s)
+			got := transProtoBytesCase(tt.args.s, tt.args.urlSafe)
 			if got != tt.want {
 				t.Errorf("transProtoBytesCase() = %v, want %v", got, tt.want)
 			}
@@ -79,27 +51,39 @@ func Test_transProtoBytesCase(t *testing.T) {
 	}
 }
 
-func transProtoStringCase(s string) string {
+func transProtoStringCase(s string, mode UTF8Mode) (string, error) {
 	var j JsonBuilder
-	transProtoString(&j, decodeBytes(s))
-	return j.String()
+	if err := transProtoString(&j, decodeBytes(s), mode); err != nil {
+		return "", err
+	}
+	return j.String(), nil
 }
 
 func Test_transProtoStringCase(t *testing.T) {
 	type args struct {
-		s string
+		s    string
+		mode UTF8Mode
 	}
 	tests := []struct {
-		name string
-		args args
-		want string
+		name    string
+		args    args
+		want    string
+		wantErr bool
 	}{
 		{name: "empty", args: args{s: ""}, want: `""`},
 		{name: "hello", args: args{s: "68656c6c6f"}, want: `"hello"`},
+		{name: "invalid_utf8", args: args{s: "68ff6f"}, wantErr: true},
+		{name: "truncated_utf8", args: args{s: "68e4bd"}, wantErr: true},
+		{name: "invalid_utf8_replace", args: args{s: "68ff6fe4bd", mode: UTF8Replace}, want: `"h�o�"`},
+		{name: "invalid_utf8_passthrough", args: args{s: "68ff6f", mode: UTF8Passthrough}, want: `"h�o"`},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := transProtoStringCase(tt.args.s)
+			got, err := transProtoStringCase(tt.args.s, tt.args.mode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("transProtoStringCase() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
 			if got != tt.want {
 				t.Errorf("transProtoStringCase() = %v, want %v", got, tt.want)
 			}
@@ -108,9 +92,9 @@ func Test_transProtoStringCase(t *testing.T) {
 }
 
 func transProtoSimpleValueCase(kind Kind, s string) string {
-	pv, _ := readProtoValue(proto.NewDecoder(decodeBytes(s)), getFieldWireType(kind, false))
+	pv, _ := proto.NewDecoder(decodeBytes(s)).ReadValue(0, getFieldWireType(kind, false))
 	var j JsonBuilder
-	transProtoSimpleValue(&j, kind, pv.x)
+	transProtoSimpleValue(&j, kind, pv.X)
 	return j.String()
 }
 
@@ -168,12 +152,13 @@ func splitBytesElements(p string) [][]byte {
 }
 
 func transProtoRepeatedCase(p string, field *Field, s string) (string, error) {
-	occ := []fieldScan{{wire: protowire.BytesType, val: protoValue{s: decodeBytes(s)}}}
+	occ := []fieldScan{{wire: protowire.BytesType, val: proto.Value{S: decodeBytes(s)}}}
 	for _, e := range splitBytesElements(p) {
-		occ = append(occ, fieldScan{wire: protowire.BytesType, val: protoValue{s: e}})
+		occ = append(occ, fieldScan{wire: protowire.BytesType, val: proto.Value{S: e}})
 	}
 	var j JsonBuilder
-	if err := transProtoRepeated(&j, field, occ); err != nil {
+	st := newPtojState(&defaultToJsonOptions)
+	if err := st.transProtoRepeated(&j, field, occ); err != nil {
 		return "", err
 	}
 	return j.String(), nil
@@ -210,9 +195,10 @@ func Test_transProtoRepeatedBytesCase(t *testing.T) {
 }
 
 func transProtoPackedArrayCase(p string, field *Field) (string, error) {
-	occ := []fieldScan{{wire: protowire.BytesType, val: protoValue{s: decodeBytes(p)}}}
+	occ := []fieldScan{{wire: protowire.BytesType, val: proto.Value{S: decodeBytes(p)}}}
 	var j JsonBuilder
-	if err := transProtoRepeated(&j, field, occ); err != nil {
+	st := newPtojState(&defaultToJsonOptions)
+	if err := st.transProtoRepeated(&j, field, occ); err != nil {
 		return "", err
 	}
 	return j.String(), nil
@@ -252,12 +238,13 @@ func transProtoMapCase(p string, tag uint32, entry *Message, s string) (string,
 	entries := [][]byte{decodeBytes(s)}
 	entries = append(entries, splitBytesElements(p)...)
 	var j JsonBuilder
+	st := newPtojState(&defaultToJsonOptions)
 	j.AppendByte('{')
 	for k, e := range entries {
 		if k > 0 {
 			j.AppendByte(',')
 		}
-		if err := transProtoMapEntry(&j, entry, e); err != nil {
+		if err := st.transProtoMapEntry(&j, entry, proto.Value{S: e}); err != nil {
 			return "", err
 		}
 	}
@@ -287,6 +274,7 @@ func Test_transProtoMapCase(t *testing.T) {
 		{name: "default_numeric_key", args: args{p: "", tag: 1, entry: getTestMapEntry(Int32Kind, Int32Kind, nil), s: "1007"}, want: `{"0":7}`},
 		{name: "default_int32_value", args: args{p: "", tag: 1, entry: getTestMapEntry(StringKind, Int32Kind, nil), s: "0a0161"}, want: `{"a":0}`},
 		{name: "default_string_value", args: args{p: "", tag: 1, entry: getTestMapEntry(StringKind, StringKind, nil), s: "0a0161"}, want: `{"a":""}`},
+		{name: "unknown_group", args: args{p: "", tag: 1, entry: getTestMapEntry(StringKind, Int32Kind, nil), s: "0a01611b08011c1001"}, want: `{"a":1}`},
 		{name: "default_message_value", args: args{p: "", tag: 1, entry: getTestMapEntry(StringKind, MessageKind, getTestSimpleMessage()), s: "0a0161"}, want: `{"a":{}}`},
 	}
 	for _, tt := range tests {
@@ -305,7 +293,8 @@ func Test_transProtoMapCase(t *testing.T) {
 
 func transProtoMessageCase(p string, msg *Message) (string, error) {
 	var j JsonBuilder
-	err := transProtoMessage(&j, proto.NewDecoder(decodeBytes(p)), msg)
+	st := newPtojState(&defaultToJsonOptions)
+	err := st.transProtoMessage(&j, proto.NewDecoder(decodeBytes(p)), msg)
 	if err != nil {
 		return "", err
 	}
@@ -335,7 +324,13 @@ func Test_transProtoMessageCase(t *testing.T) {
 		{name: "emitted", args: args{p: "0a03626f6210170a03626f621801", msg: getTestSimpleMessage()}, want: `{"name":"bob","age":23}`},
 		{name: "complex", args: args{p: complexProto, msg: getTestComplexMessage()}, want: complexWant},
 		{name: "default", args: args{p: "", msg: getTestComplexMessage()}, want: complexDefaultWant},
+		{name: "unknown_group", args: args{p: "0a03626f622b330801342c1017", msg: getTestSimpleMessage()}, want: `{"name":"bob","age":23}`},
+		{name: "unknown_end_group", args: args{p: "0a03626f622c", msg: getTestSimpleMessage()}, wantErr: true},
 		{name: "eof", args: args{p: "0a", msg: getTestComplexMessage()}, wantErr: true},
+		{name: "out_of_order", args: args{p: "10170a03626f62", msg: getTestSimpleMessage()}, want: `{"name":"bob","age":23}`},
+		{name: "adjacent_singular", args: args{p: "0a036162630a03626f621017", msg: getTestSimpleMessage()}, want: `{"name":"bob","age":23}`},
+		{name: "split_repeated", args: args{p: "98010198010209000000000000f03f980103", msg: getTestComplexMessage()}, want: `{"fdouble":1,"ffloat":0,"fint32":0,"fint64":0,"fuint32":0,"fuint64":0,"fsint32":0,"fsint64":0,"ffixed32":0,"ffixed64":0,"fsfixed32":0,"fsfixed64":0,"fbool":false,"fstring":"","fbytes":"","fmap1":{},"fmap2":{},"fsubmsg":{},"fint32s":[1,2,3],"fitems":[]}`},
+		{name: "ordered_wire_type", args: args{p: "0d00000000", msg: getTestSimpleMessage()}, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -351,6 +346,113 @@ func Test_transProtoMessageCase(t *testing.T) {
 	}
 }
 
+func Test_inFieldOrder(t *testing.T) {
+	nested := NewMessage("", []Field{
+		{Name: "name", Tag: 1, Kind: StringKind},
+		{Name: "sub", Tag: 2, Kind: MessageKind, Ref: getTestSimpleMessage()},
+	}, true, true)
+	tests := []struct {
+		name  string
+		p     string
+		msg   *Message
+		depth int
+		want  bool
+	}{
+		{name: "empty", p: "", want: true},
+		{name: "ordered", p: "0a03626f621017", want: true},
+		{name: "gap", p: "0a03626f62", want: true},
+		{name: "adjacent_singular", p: "0a036162630a03626f621017", want: true},
+		{name: "unknown_interleaved", p: "0a03626f6220011017", want: true},
+		{name: "omit_always_out_of_order", p: "18010a03626f621017", want: true},
+		{name: "out_of_order", p: "10170a03626f62", want: false},
+		{name: "split", p: "0a03626f6210170a03626f62", want: false},
+		{name: "wire_type", p: "0d00000000", want: false},
+		{name: "eof", p: "0a", want: false},
+		// 子消息同样检查
+		{name: "nested_ordered", p: "0a0161" + "1207" + "0a03626f621017", msg: nested, want: true},
+		{name: "nested_out_of_order", p: "0a0161" + "1207" + "10170a03626f62", msg: nested, want: false},
+		{name: "nested_depth", p: "0a0161" + "1207" + "0a03626f621017", msg: nested, depth: 2, want: false},
+		{name: "nested_depth_ok", p: "0a0161" + "1207" + "0a03626f621017", msg: nested, depth: 3, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			if msg == nil {
+				msg = getTestSimpleMessage()
+			}
+			if got := inFieldOrder(*proto.NewDecoder(decodeBytes(tt.p)), msg, tt.depth); got != tt.want {
+				t.Errorf("inFieldOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_transProtoOrdered(t *testing.T) {
+	tests := []struct {
+		name      string
+		p         string
+		wantOrder bool
+	}{
+		{name: "empty", p: ""},
+		{name: "ordered", p: "0a03626f621017"},
+		{name: "gap", p: "0a03626f62"},
+		{name: "adjacent_singular", p: "0a036162630a03626f621017"},
+		{name: "unknown_interleaved", p: "0a03626f6220011017"},
+		{name: "omit_always_out_of_order", p: "18010a03626f621017"},
+		{name: "out_of_order", p: "10170a03626f62", wantOrder: true},
+		{name: "split", p: "0a03626f6210170a03626f62", wantOrder: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newPtojState(&defaultToJsonOptions)
+			err := st.transProtoOrdered(&JsonBuilder{}, proto.NewDecoder(decodeBytes(tt.p)), getTestSimpleMessage(), true)
+			if (err == ErrFieldOrder) != tt.wantOrder {
+				t.Errorf("transProtoOrdered() error = %v, wantOrder %v", err, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestTranscodeToJson_fallback(t *testing.T) {
+	msg := NewMessage("", []Field{
+		{Name: "name", Tag: 1, Kind: StringKind, ValidateUTF8: true},
+		{Name: "age", Tag: 2, Kind: Int32Kind, Required: true},
+	}, true, true)
+	tests := []struct {
+		name string
+		p    string
+		want string
+	}{
+		// 已输出的内容撤销后重新输出，之前追加的内容保留
+		{name: "out_of_order", p: "10170a03626f62", want: `x{"name":"bob","age":23}`},
+		// 非连续出现的非重复字段取最后一次出现，之前的非法值不报错
+		{name: "bad_then_good", p: "0a01ff10170a03626f62", want: `x{"name":"bob","age":23}`},
+		// required 字段在后面乱序出现
+		{name: "late_required", p: "0a03626f6210170a0161", want: `x{"name":"a","age":23}`},
+		{name: "in_order", p: "0a03626f621017", want: `x{"name":"bob","age":23}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := JsonBuilder{}
+			j.AppendByte('x')
+			if err := TranscodeToJson(&j, proto.NewDecoder(decodeBytes(tt.p)), msg); err != nil {
+				t.Fatal(err)
+			}
+			if j.String() != tt.want {
+				t.Fatalf("TranscodeToJson() = %s, want %s", j.String(), tt.want)
+			}
+		})
+	}
+
+	// 缺失 required 字段与非法的最后一次出现仍然报错
+	for _, p := range []string{"0a03626f62", "10170a01ff"} {
+		var j JsonBuilder
+		if err := TranscodeToJson(&j, proto.NewDecoder(decodeBytes(p)), msg); err == nil || err == ErrFieldOrder {
+			t.Errorf("TranscodeToJson(%s) error = %v", p, err)
+		}
+	}
+}
+
 func TestTranscodeToJson(t *testing.T) {
 	type args struct {
 		j   *JsonBuilder
@@ -372,3 +474,116 @@ func TestTranscodeToJson(t *testing.T) {
 		})
 	}
 }
+
+func TestToJsonOptions_UTF8(t *testing.T) {
+	pb := decodeBytes("0a0268ff") // name = "h\xff"
+	// 输出必须是合法 JSON：未设置 ValidateUTF8 的字段（手写元数据、proto2 string）同样检查
+	for _, msg := range []*Message{
+		getTestSimpleMessage(),
+		NewMessage("", []Field{{Name: "name", Tag: 1, Kind: StringKind, ValidateUTF8: true}}, true, true),
+	} {
+		var j JsonBuilder
+		if err := TranscodeToJson(&j, proto.NewDecoder(pb), msg); !errors.Is(err, ErrInvalidUTF8) {
+			t.Fatalf("TranscodeToJson() error = %v, want ErrInvalidUTF8", err)
+		}
+		for _, mode := range []UTF8Mode{UTF8Replace, UTF8Passthrough} {
+			opts := ToJsonOptions{UTF8: mode}
+			j = JsonBuilder{}
+			if err := opts.Transcode(&j, proto.NewDecoder(pb), msg); err != nil {
+				t.Fatal(err)
+			}
+			if want := `{"name":"h�"}`; j.String() != want {
+				t.Fatalf("Transcode() = %s, want %s", j.String(), want)
+			}
+		}
+	}
+}
+
+func TestToJsonOptions_URLSafeBytes(t *testing.T) {
+	msg := NewMessage("", []Field{
+		{Name: "b", Tag: 1, Kind: BytesKind},
+		{Name: "bs", Tag: 2, Kind: BytesKind, Repeated: true},
+	}, true, true)
+	pb := decodeBytes("0a02fbff" + "1203fbfffb")
+	opts := ToJsonOptions{URLSafeBytes: true}
+	var j JsonBuilder
+	if err := opts.Transcode(&j, proto.NewDecoder(pb), msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":"-_8=","bs":["-__7"]}`; j.String() != want {
+		t.Fatalf("Transcode() = %s, want %s", j.String(), want)
+	}
+}
+
+func TestTranscodeToJson_protoError(t *testing.T) {
+	var j JsonBuilder
+	// age 的 varint 被截断
+	err := TranscodeToJson(&j, proto.NewDecoder(decodeBytes("0a03626f6210")), getTestSimpleMessage())
+	var perr *proto.Error
+	if !errors.As(err, &perr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("TranscodeToJson() error = %v", err)
+	}
+	if perr.Offset != 6 || perr.Tag != 2 || perr.Wire != protowire.VarintType {
+		t.Errorf("TranscodeToJson() error = %+v", perr)
+	}
+}
+
+func TestTranscodeToJson_nestedProtoError(t *testing.T) {
+	msg := NewMessage("", []Field{
+		{Name: "sub", Tag: 1, Kind: MessageKind, Ref: getTestSimpleMessage()},
+		{Name: "ids", Tag: 2, Kind: Int32Kind, Repeated: true},
+		{Name: "m", Tag: 3, Kind: MapKind, Ref: NewMessage("", []Field{
+			{Tag: 1, Kind: StringKind},
+			{Tag: 2, Kind: MessageKind, Ref: getTestSimpleMessage()},
+		}, true, true)},
+	}, true, true)
+	// Offset 是整个输入中的偏移，packed 元素的错误带有所在字段的 tag
+	tests := []struct {
+		name   string
+		p      string
+		offset int64
+		tag    uint32
+		wire   protowire.Type
+	}{
+		{name: "sub", p: "0a02" + "1080", offset: 3, tag: 2, wire: protowire.VarintType},
+		{name: "packed", p: "1202" + "0180", offset: 3, tag: 2, wire: protowire.BytesType},
+		{name: "map_value", p: "1a06" + "0a0161" + "1201" + "10", offset: 8, tag: 2, wire: protowire.VarintType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var j JsonBuilder
+			err := TranscodeToJson(&j, proto.NewDecoder(decodeBytes(tt.p)), msg)
+			var perr *proto.Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("TranscodeToJson() error = %v", err)
+			}
+			if perr.Offset != tt.offset || perr.Tag != tt.tag || perr.Wire != tt.wire {
+				t.Errorf("TranscodeToJson() error = %+v", perr)
+			}
+		})
+	}
+}
+
+func TestTranscodeToJson_fallbackStream(t *testing.T) {
+	// 写出到 io.Writer 时不能撤销，乱序输入直接两遍处理，错误不会报告为 ErrFieldOrder
+	msg := NewMessage("", []Field{
+		{Name: "name", Tag: 1, Kind: StringKind},
+		{Name: "age", Tag: 2, Kind: Int32Kind, Required: true},
+	}, true, true)
+	for p, want := range map[string]string{
+		"10170a03626f62": `{"name":"bob","age":23}`,
+		"0a03626f62":     "",
+	} {
+		var w bytes.Buffer
+		err := TranscodeToJson(NewStreamJsonBuilder(&w, 0), proto.NewDecoder(decodeBytes(p)), msg)
+		if want == "" {
+			if !errors.Is(err, ErrRequiredField) {
+				t.Errorf("TranscodeToJson(%s) error = %v", p, err)
+			}
+			continue
+		}
+		if err != nil || w.String() != want {
+			t.Errorf("TranscodeToJson(%s) = %s, %v", p, w.String(), err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package jsonpb
+
+import "errors"
+
+var ErrRequiredField = errors.New("required field not set")
+
+// RequiredFieldError 描述缺失的 required 字段，Path 为从顶层消息开始以 '.' 连接的字段名，
+// 可用 errors.Is(err, ErrRequiredField) 判断。
+type RequiredFieldError struct {
+	Path string
+}
+
+func (e *RequiredFieldError) Error() string {
+	return "required field " + e.Path + " not set"
+}
+
+func (e *RequiredFieldError) Unwrap() error {
+	return ErrRequiredField
+}
+
+// withFieldPath 在子消息返回的 RequiredFieldError 路径前加上外层字段名。
+func withFieldPath(err error, name string) error {
+	if rerr, ok := err.(*RequiredFieldError); ok {
+		rerr.Path = name + "." + rerr.Path
+	}
+	return err
+}
+
+// checkRequired 检查 msg 的 required 字段是否都已设置，isSet 判断第 i 个字段是否出现。
+// OmitAlways 的字段不参与检查。
+func checkRequired(msg *Message, isSet func(i int) bool) error {
+	for i := range msg.Fields {
+		field := &msg.Fields[i]
+		if field.Required && field.Omit != OmitAlways && !isSet(i) {
+			return &RequiredFieldError{Path: field.Name}
+		}
+	}
+	return nil
+}
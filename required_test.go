@@ -0,0 +1,103 @@
+package jsonpb
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func getTestRequiredMessage() *Message {
+	msg := &Message{Name: "Req"}
+	msg.Fields = []Field{
+		{Name: "id", Tag: 1, Kind: Int32Kind, Required: true},
+		{Name: "name", Tag: 2, Kind: StringKind, Default: `"anon"`},
+		{Name: "child", Tag: 3, Kind: MessageKind, Ref: msg},
+		{Name: "items", Tag: 4, Kind: MessageKind, Ref: msg, Repeated: true},
+		{Name: "skip", Tag: 5, Kind: BoolKind, Required: true, Omit: OmitAlways},
+	}
+	msg.BakeTagIndex()
+	msg.BakeNameIndex()
+	return msg
+}
+
+func TestTranscodeToProto_required(t *testing.T) {
+	tests := []struct {
+		name     string
+		j        string
+		want     string
+		wantPath string
+	}{
+		{name: "zero_written", j: `{"id":0,"name":""}`, want: "08001200"},
+		{name: "missing", j: `{"name":"a"}`, wantPath: "id"},
+		{name: "null", j: `{"id":null}`, wantPath: "id"},
+		{name: "nested", j: `{"id":1,"child":{"id":2,"child":{}}}`, wantPath: "child.child.id"},
+		{name: "repeated", j: `{"id":1,"items":[{"id":2},{"name":"x"}]}`, wantPath: "items.id"},
+		{name: "deterministic", j: `{"name":"","id":0}`, want: "08001200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ToProtoOptions{Deterministic: tt.name == "deterministic"}
+			var p proto.Encoder
+			err := opts.Transcode(&p, jsonlit.NewIter([]byte(tt.j)), getTestRequiredMessage())
+			if tt.wantPath != "" {
+				var rerr *RequiredFieldError
+				if !errors.As(err, &rerr) || rerr.Path != tt.wantPath || !errors.Is(err, ErrRequiredField) {
+					t.Fatalf("Transcode() error = %v, want path %s", err, tt.wantPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(p.Bytes()); got != tt.want {
+				t.Fatalf("Transcode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscodeToJson_required(t *testing.T) {
+	tests := []struct {
+		name     string
+		pb       string
+		want     string
+		wantPath string
+	}{
+		{name: "default", pb: "0801", want: `{"id":1,"name":"anon","child":{},"items":[]}`},
+		{name: "missing", pb: "1200", wantPath: "id"},
+		{name: "nested", pb: "08011a00", wantPath: "child.id"},
+		{name: "repeated", pb: "0801220208022200", wantPath: "items.id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := getTestRequiredMessage()
+			pb := decodeBytes(tt.pb)
+			for _, reader := range []bool{false, true} {
+				var j JsonBuilder
+				var err error
+				if reader {
+					err = TranscodeReaderToJson(&j, proto.NewReaderDecoder(strings.NewReader(string(pb)), 0), msg)
+				} else {
+					err = TranscodeToJson(&j, proto.NewDecoder(pb), msg)
+				}
+				if tt.wantPath != "" {
+					var rerr *RequiredFieldError
+					if !errors.As(err, &rerr) || rerr.Path != tt.wantPath {
+						t.Fatalf("reader=%v: error = %v, want path %s", reader, err, tt.wantPath)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if j.String() != tt.want {
+					t.Fatalf("reader=%v: got %s, want %s", reader, j.String(), tt.want)
+				}
+			}
+		})
+	}
+}
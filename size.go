@@ -0,0 +1,59 @@
+package jsonpb
+
+import (
+	"sync"
+
+	"github.com/vizee/jsonpb/proto"
+)
+
+// EstimateJsonSize 粗略估计把长度为 pbLen 的 pb 按 msg 转为 JSON 后的字节数，用于 AcquireJsonBuilder 或 Reserve 预留容量。
+// 估计由两部分组成：顶层字段名与未出现字段默认值的固定开销（proto->json 总会输出未省略的字段），
+// 以及按 wire 长度放大的值部分（数值的十进制、base64 与嵌套消息的字段名通常都比 wire 上更长）。
+// 结果只是预留容量的提示，不是上界：嵌套消息每次出现都会输出其字段名与默认值，
+// 例如大量空的 repeated 子消息在 wire 上各只占两个字节，输出可以远大于估计，此时缓冲按需增长。
+func EstimateJsonSize(msg *Message, pbLen int) int {
+	n := 2
+	for i := range msg.Fields {
+		field := &msg.Fields[i]
+		if field.Omit == OmitAlways {
+			continue
+		}
+		// "name":value,
+		n += len(field.Name) + 4
+		if field.Omit == OmitProtoEmpty {
+			if field.Default != "" {
+				n += len(field.Default)
+			} else {
+				n += 2
+			}
+		}
+	}
+	return n + pbLen*2
+}
+
+// sizeEncoderPool 复用只计算长度的 Encoder，保留其嵌套子消息的栈
+var sizeEncoderPool = sync.Pool{
+	New: func() any {
+		return proto.NewSizeEncoder()
+	},
+}
+
+// Size 计算把 j 中的 JSON 按 msg 与 o 的选项转为 pb 后的准确字节数，只做计算不写出内容，
+// 结果与之后对同一输入调用 o.Transcode 的输出长度一致。j 会被读到对象末尾，再次转码前需要 Reset。
+func (o *ToProtoOptions) Size(j JsonLexer, msg *Message) (int, error) {
+	p := sizeEncoderPool.Get().(*proto.Encoder)
+	err := o.Transcode(p, j, msg)
+	n := p.Len()
+	p.Clear()
+	sizeEncoderPool.Put(p)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// SizeOfJson 计算把 JSON 按 msg 转为 pb 后的准确字节数，与 TranscodeToProto 的输出长度一致，见 ToProtoOptions.Size。
+// 需要先知道 body 长度再写出时（例如 HTTP/2 分帧），用它得到长度后再由 TranscodeToProtoInto 直接写入按该长度分配的缓冲。
+func SizeOfJson(j JsonLexer, msg *Message) (int, error) {
+	return defaultToProtoOptions.Size(j, msg)
+}
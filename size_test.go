@@ -0,0 +1,111 @@
+package jsonpb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vizee/jsonpb/jsonlit"
+	"github.com/vizee/jsonpb/proto"
+)
+
+func TestSizeOfJson(t *testing.T) {
+	big := strings.Repeat("x", 300)
+	tests := []struct {
+		name string
+		j    string
+		opts ToProtoOptions
+	}{
+		{name: "empty", j: `{}`},
+		{name: "complex", j: benchComplexJson},
+		{name: "empty_submsg", j: `{"fsubmsg":{},"fitems":[{},null,{"name":""}]}`},
+		{name: "long_submsg", j: `{"fsubmsg":{"name":"` + big + `"},"fmap2":{"` + big + `":{"name":"` + big + `"}}}`},
+		{name: "packed", j: `{"fint32s":[1,-1,300,0],"fmap1":{"a":1,"":0}}`},
+		{name: "deterministic", j: `{"fmap1":{"b":2,"a":1,"b":3},"fsubmsg":{},"fint32s":[]}`, opts: ToProtoOptions{Deterministic: true}},
+	}
+	msg := getTestComplexMessage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want proto.Encoder
+			if err := tt.opts.Transcode(&want, jsonlit.NewIter([]byte(tt.j)), msg); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.opts.Size(jsonlit.NewIter([]byte(tt.j)), msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want.Len() {
+				t.Fatalf("Size() = %d, want %d", got, want.Len())
+			}
+		})
+	}
+
+	if _, err := SizeOfJson(jsonlit.NewIter([]byte(`{"fint32":"x"}`)), msg); err == nil {
+		t.Fatal("SizeOfJson() expected error")
+	}
+}
+
+func TestTranscodeToProtoInto(t *testing.T) {
+	msg := getTestComplexMessage()
+	for _, j := range []string{benchComplexJson, `{}`, `{"fitems":[{}],"fstring":"a","fsubmsg":{}}`} {
+		var want proto.Encoder
+		if err := TranscodeToProto(&want, jsonlit.NewIter([]byte(j)), msg); err != nil {
+			t.Fatal(err)
+		}
+
+		it := jsonlit.NewIter([]byte(j))
+		size, err := SizeOfJson(it, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it.Reset([]byte(j))
+		dst := make([]byte, size)
+		n, err := TranscodeToProtoInto(dst, it, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size || !bytes.Equal(dst, want.Bytes()) {
+			t.Fatalf("TranscodeToProtoInto() = %d %x, want %x", n, dst, want.Bytes())
+		}
+
+		if size > 0 {
+			_, err := TranscodeToProtoInto(make([]byte, size-1), jsonlit.NewIter([]byte(j)), msg)
+			if !errors.Is(err, io.ErrShortBuffer) {
+				t.Fatalf("TranscodeToProtoInto() error = %v, want io.ErrShortBuffer", err)
+			}
+		}
+	}
+}
+
+func TestTranscodeToProtoInto_noAlloc(t *testing.T) {
+	msg := getTestComplexMessage()
+	// 子消息超过 128 字节，长度前缀需要后移内容
+	j := []byte(`{"fsubmsg":{"name":"` + strings.Repeat("a", 300) + `","age":1},"fitems":[{"name":"b"},{}],"fint32":1}`)
+	size, err := SizeOfJson(jsonlit.NewIter(j), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make([]byte, size)
+	it := jsonlit.NewIter(j)
+	allocs := testing.AllocsPerRun(10, func() {
+		it.Reset(j)
+		if _, err := TranscodeToProtoInto(dst, it, msg); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("TranscodeToProtoInto() allocs = %v", allocs)
+	}
+
+	// 确定超出 dst 后即停止，不再处理剩余的输入
+	long := []byte(`{"fstring":"abc","fitems":[` + strings.Repeat(`{"name":"x"},`, 1000) + `{}]}`)
+	it.Reset(long)
+	if _, err := TranscodeToProtoInto(make([]byte, 8), it, msg); !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("TranscodeToProtoInto() error = %v, want io.ErrShortBuffer", err)
+	}
+	if it.Offset() > len(long)/10 {
+		t.Fatalf("read %d of %d bytes after the buffer was full", it.Offset(), len(long))
+	}
+}
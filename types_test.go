@@ -1,5 +1,12 @@
 package jsonpb
 
+import (
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
 func getTestSimpleMessage() *Message {
 	return NewMessage("Simple", []Field{
 		{Name: "name", Tag: 1, Kind: StringKind, Omit: OmitEmpty},
@@ -47,3 +54,153 @@ func getTestComplexMessage() *Message {
 		{Name: "fitems", Kind: MessageKind, Tag: 20, Repeated: true, Ref: getTestSimpleMessage()},
 	}, true, true)
 }
+
+// getTestDetMessage 返回字段定义顺序与 tag 顺序不一致的消息及其等价的 proto3 描述符，用于与官方实现对比输出。
+func getTestDetMessage() (*Message, protoreflect.MessageDescriptor) {
+	msg := &Message{Name: "Det"}
+	msg.Fields = []Field{
+		{Name: "name", Kind: StringKind, Tag: 3},
+		{Name: "nums", Kind: Int32Kind, Tag: 1, Repeated: true},
+		{Name: "ids", Kind: MapKind, Tag: 5, Ref: getTestMapEntry(Sint64Kind, StringKind, nil)},
+		{Name: "subs", Kind: MapKind, Tag: 2, Ref: getTestMapEntry(StringKind, MessageKind, msg)},
+		{Name: "child", Kind: MessageKind, Tag: 4, Ref: msg},
+		{Name: "score", Kind: DoubleKind, Tag: 6},
+		{Name: "flags", Kind: MapKind, Tag: 7, Ref: getTestMapEntry(Uint64Kind, BoolKind, nil)},
+	}
+	msg.BakeTagIndex()
+	msg.BakeNameIndex()
+
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+	field, entry := testFieldProto, testMapEntryProto
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    gproto.String("det.proto"),
+		Package: gproto.String("test"),
+		Syntax:  gproto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: gproto.String("Det"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("nums", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, repeated, ""),
+				field("ids", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Det.IdsEntry"),
+				field("subs", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Det.SubsEntry"),
+				field("child", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Det"),
+				field("score", 6, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional, ""),
+				field("flags", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Det.FlagsEntry"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				entry("IdsEntry", descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				entry("SubsEntry", descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Det"),
+				entry("FlagsEntry", descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		panic(err)
+	}
+	return msg, fd.Messages().Get(0)
+}
+
+func testFieldProto(name string, tag int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+	fd := &descriptorpb.FieldDescriptorProto{
+		Name:     gproto.String(name),
+		JsonName: gproto.String(name),
+		Number:   gproto.Int32(tag),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+	}
+	if typeName != "" {
+		fd.TypeName = gproto.String(typeName)
+	}
+	return fd
+}
+
+func testMapEntryProto(name string, key, value descriptorpb.FieldDescriptorProto_Type, valueType string) *descriptorpb.DescriptorProto {
+	const optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	return &descriptorpb.DescriptorProto{
+		Name: gproto.String(name),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testFieldProto("key", 1, key, optional, ""),
+			testFieldProto("value", 2, value, optional, valueType),
+		},
+		Options: &descriptorpb.MessageOptions{MapEntry: gproto.Bool(true)},
+	}
+}
+
+func withDefault(fd *descriptorpb.FieldDescriptorProto, value string) *descriptorpb.FieldDescriptorProto {
+	fd.DefaultValue = gproto.String(value)
+	return fd
+}
+
+// getTestProto2Descriptor 返回 proto2 文件中的消息描述符：P2 的 repeated 标量默认不 packed，Req 含 required 与默认值字段，Legacy 含 group。
+func getTestProto2Descriptor(name protoreflect.Name) protoreflect.MessageDescriptor {
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		required = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+	packed := testFieldProto("packed", 3, descriptorpb.FieldDescriptorProto_TYPE_SINT64, repeated, "")
+	packed.Options = &descriptorpb.FieldOptions{Packed: gproto.Bool(true)}
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    gproto.String("p2.proto"),
+		Package: gproto.String("test"),
+		Syntax:  gproto.String("proto2"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: gproto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: gproto.String("RED"), Number: gproto.Int32(0)},
+				{Name: gproto.String("BLUE"), Number: gproto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: gproto.String("P2"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				testFieldProto("ints", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, repeated, ""),
+				testFieldProto("doubles", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, repeated, ""),
+				packed,
+				testFieldProto("flags", 4, descriptorpb.FieldDescriptorProto_TYPE_BOOL, repeated, ""),
+				testFieldProto("colors", 5, descriptorpb.FieldDescriptorProto_TYPE_ENUM, repeated, ".test.Color"),
+				testFieldProto("names", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, repeated, ""),
+			},
+		}, {
+			Name: gproto.String("Req"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				testFieldProto("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, required, ""),
+				withDefault(testFieldProto("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""), `n"x`),
+				withDefault(testFieldProto("ratio", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional, ""), "inf"),
+				withDefault(testFieldProto("color", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional, ".test.Color"), "BLUE"),
+				withDefault(testFieldProto("raw", 5, descriptorpb.FieldDescriptorProto_TYPE_BYTES, optional, ""), `\001\002`),
+				withDefault(testFieldProto("delta", 6, descriptorpb.FieldDescriptorProto_TYPE_SINT32, optional, ""), "-3"),
+				testFieldProto("child", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Req"),
+				withDefault(testFieldProto("on", 8, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional, ""), "true"),
+			},
+		}, {
+			Name: gproto.String("Legacy"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				testFieldProto("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				testFieldProto("item", 2, descriptorpb.FieldDescriptorProto_TYPE_GROUP, optional, ".test.Legacy.Item"),
+				testFieldProto("entry", 5, descriptorpb.FieldDescriptorProto_TYPE_GROUP, repeated, ".test.Legacy.Entry"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: gproto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					testFieldProto("name", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					testFieldProto("vals", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32, repeated, ""),
+				},
+			}, {
+				Name: gproto.String("Entry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					testFieldProto("k", 6, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				},
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd.Messages().ByName(name)
+}
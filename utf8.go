@@ -0,0 +1,43 @@
+package jsonpb
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+)
+
+var ErrInvalidUTF8 = errors.New("string field contains invalid UTF-8")
+
+// UTF8Mode 控制 string 字段遇到非法 UTF-8 时的处理方式，零值与 proto3 的要求一致。
+// json->proto 只检查设置了 Field.ValidateUTF8 的字段；proto->json 检查所有 string 字段。
+type UTF8Mode uint8
+
+const (
+	// UTF8Error 返回 ErrInvalidUTF8
+	UTF8Error UTF8Mode = iota
+	// UTF8Replace 把每段非法字节替换为 U+FFFD
+	UTF8Replace
+	// UTF8Passthrough 不做检查，原样输出；只用于 json->proto，proto->json 时按 UTF8Replace 处理
+	UTF8Passthrough
+)
+
+var replacementChar = []byte("�")
+
+// fieldUTF8Mode 返回 json->proto 时 field 实际使用的处理方式，未设置 ValidateUTF8 的字段不做检查。
+func fieldUTF8Mode(field *Field, mode UTF8Mode) UTF8Mode {
+	if !field.ValidateUTF8 {
+		return UTF8Passthrough
+	}
+	return mode
+}
+
+// validUTF8 按 mode 检查 s，合法时原样返回 s（不复制）。
+func validUTF8(s []byte, mode UTF8Mode) ([]byte, error) {
+	if mode == UTF8Passthrough || utf8.Valid(s) {
+		return s, nil
+	}
+	if mode == UTF8Replace {
+		return bytes.ToValidUTF8(s, replacementChar), nil
+	}
+	return nil, ErrInvalidUTF8
+}